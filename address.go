@@ -0,0 +1,562 @@
+package rmime
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bobg/errors"
+)
+
+// AddressItem is an element of an AddressList:
+// either an *Address or a *Group.
+type AddressItem interface {
+	fmt.Stringer
+
+	// addresses returns the addresses in the item:
+	// the *Address itself,
+	// or the members of the *Group.
+	addresses() []*Address
+}
+
+// Group is a named group of addresses (RFC5322 section 3.4),
+// e.g. "undisclosed-recipients:;" (with no members)
+// or "Team: a@example.com, b@example.com;".
+type Group struct {
+	Name    string     `json:"name"` // Decoded.
+	Members []*Address `json:"members,omitempty"`
+}
+
+// AddressList is the parsed value of an address field,
+// such as From or To.
+type AddressList []AddressItem
+
+// Addresses returns the addresses in al,
+// including the members of groups.
+func (al AddressList) Addresses() []*Address {
+	var result []*Address
+	for _, item := range al {
+		result = append(result, item.addresses()...)
+	}
+	return result
+}
+
+// String renders al in a form suitable for the value of an address field,
+// quoting and encoding display names as necessary.
+func (al AddressList) String() string {
+	strs := make([]string, 0, len(al))
+	for _, item := range al {
+		strs = append(strs, item.String())
+	}
+	return strings.Join(strs, ", ")
+}
+
+// String renders a as it would appear in an address field,
+// e.g. "Name <local@domain>" or "local@domain",
+// quoting and encoding the display name
+// and quoting the local part as necessary.
+func (a *Address) String() string {
+	addr := (&mail.Address{Address: a.Address}).String() // quotes the local part if necessary
+	if a.Name == "" {
+		return strings.TrimSuffix(strings.TrimPrefix(addr, "<"), ">")
+	}
+	return encodePhrase(a.Name) + " " + addr
+}
+
+func (a *Address) addresses() []*Address {
+	return []*Address{a}
+}
+
+// String renders g as it would appear in an address field,
+// e.g. "Name: a@example.com, b@example.com;".
+func (g *Group) String() string {
+	strs := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		strs = append(strs, m.String())
+	}
+	return encodePhrase(g.Name) + ": " + strings.Join(strs, ", ") + ";"
+}
+
+func (g *Group) addresses() []*Address {
+	return g.Members
+}
+
+// ParseAddressList parses s as the value of an address field
+// (RFC5322 section 3.4),
+// including groups.
+// Display names are decoded.
+// An empty (or all-whitespace) s produces an empty list.
+func ParseAddressList(s string) (AddressList, error) {
+	p := &addrParser{s: s}
+	return p.parseAddressList()
+}
+
+// Addresses parses all fields in h with the given name
+// as address fields (see ParseAddressList)
+// and returns their contents.
+// It returns nil if there are no such fields.
+func (h Header) Addresses(name string) (AddressList, error) {
+	var result AddressList
+	for _, v := range h.fieldValues(name) {
+		list, err := ParseAddressList(v)
+		if err != nil {
+			return result, errors.Wrapf(err, "parsing %s field", name)
+		}
+		result = append(result, list...)
+	}
+	return result, nil
+}
+
+// From returns the contents of the From field(s) of h:
+// the authors of the message.
+func (h Header) From() (AddressList, error) { return h.Addresses("From") }
+
+// ReplyTo returns the contents of the Reply-To field(s) of h.
+func (h Header) ReplyTo() (AddressList, error) { return h.Addresses("Reply-To") }
+
+// To returns the contents of the To field(s) of h.
+func (h Header) To() (AddressList, error) { return h.Addresses("To") }
+
+// Cc returns the contents of the Cc field(s) of h.
+func (h Header) Cc() (AddressList, error) { return h.Addresses("Cc") }
+
+// Bcc returns the contents of the Bcc field(s) of h.
+func (h Header) Bcc() (AddressList, error) { return h.Addresses("Bcc") }
+
+// ResentFrom returns the contents of the Resent-From field(s) of h.
+func (h Header) ResentFrom() (AddressList, error) { return h.Addresses("Resent-From") }
+
+// ResentTo returns the contents of the Resent-To field(s) of h.
+func (h Header) ResentTo() (AddressList, error) { return h.Addresses("Resent-To") }
+
+// ResentCc returns the contents of the Resent-Cc field(s) of h.
+func (h Header) ResentCc() (AddressList, error) { return h.Addresses("Resent-Cc") }
+
+// ResentBcc returns the contents of the Resent-Bcc field(s) of h.
+func (h Header) ResentBcc() (AddressList, error) { return h.Addresses("Resent-Bcc") }
+
+// DispositionNotificationTo returns the contents of the Disposition-Notification-To field(s) of h
+// (the request for a read receipt, RFC8098).
+func (h Header) DispositionNotificationTo() (AddressList, error) {
+	return h.Addresses("Disposition-Notification-To")
+}
+
+// SenderField returns the address in the Sender field of h
+// (the agent responsible for transmitting the message,
+// when that is not its author; RFC5322 section 3.6.2),
+// parsed leniently (see ParseAddressListLenient),
+// or nil if there is none.
+// Compare Sender.
+func (h Header) SenderField() *Address {
+	return h.firstAddress("Sender")
+}
+
+// ResentSender returns the address in the Resent-Sender field of h,
+// parsed leniently (see ParseAddressListLenient),
+// or nil if there is none.
+func (h Header) ResentSender() *Address {
+	return h.firstAddress("Resent-Sender")
+}
+
+func (h Header) firstAddress(name string) *Address {
+	f := h.findField(name)
+	if f == nil {
+		return nil
+	}
+	list, _ := ParseAddressListLenient(f.Value())
+	if addrs := list.Addresses(); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return nil
+}
+
+// addrParser is a recursive-descent parser for address lists.
+// Its lexical methods (skipCFWS, parseQuotedString, and so on)
+// serve for other structured fields too.
+type addrParser struct {
+	s   string
+	pos int
+
+	// In lenient mode,
+	// the parser repairs what it can of malformed input
+	// (see ParseAddressListLenient)
+	// and records what it did in warnings.
+	lenient  bool
+	warnings []*Warning
+}
+
+func (p *addrParser) errorf(format string, args ...interface{}) error {
+	return errors.Wrapf(ErrHeaderSyntax, "at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *addrParser) parseAddressList() (AddressList, error) {
+	var result AddressList
+	for {
+		p.skipCFWS()
+		if p.atEnd() {
+			return result, nil
+		}
+		if p.consume(',') {
+			// Empty list element (obs-addr-list).
+			continue
+		}
+		start, nwarn := p.pos, len(p.warnings)
+		item, err := p.parseAddress()
+		_, isGroup := item.(*Group)
+		if err == nil {
+			p.skipCFWS()
+			if !p.atEnd() && !p.peek(',') {
+				err = p.errorf("expected comma")
+			}
+		}
+		switch {
+		case err == nil:
+			result = append(result, item)
+		case !p.lenient:
+			return nil, err
+		case isGroup:
+			// A group followed by junk.
+			result = append(result, item)
+			p.skipJunk(false)
+		default:
+			p.pos, p.warnings = start, p.warnings[:nwarn]
+			if a := p.recoverMailbox(false); a != nil {
+				result = append(result, a)
+			}
+		}
+		p.consume(',')
+	}
+}
+
+// parseAddress parses a mailbox or a group.
+func (p *addrParser) parseAddress() (AddressItem, error) {
+	start := p.pos
+	words, err := p.parseWords()
+	if err != nil {
+		return nil, err
+	}
+	p.skipCFWS()
+	if p.consume(':') {
+		if len(words) == 0 {
+			return nil, p.errorf("missing group name")
+		}
+		g := &Group{Name: p.decodePhrase(words)}
+		for {
+			p.skipCFWS()
+			if p.consume(';') {
+				return g, nil
+			}
+			if p.atEnd() {
+				if p.lenient {
+					p.warn(WarnAddressSyntax, "unterminated group %q", g.Name)
+					return g, nil
+				}
+				return nil, p.errorf("unterminated group")
+			}
+			if p.consume(',') {
+				continue
+			}
+			start, nwarn := p.pos, len(p.warnings)
+			a, err := p.parseMailbox()
+			if err == nil {
+				p.skipCFWS()
+				if !p.peek(';') && !p.peek(',') {
+					err = p.errorf("expected comma or semicolon in group")
+				}
+			}
+			if err != nil {
+				if !p.lenient {
+					return nil, err
+				}
+				p.pos, p.warnings = start, p.warnings[:nwarn]
+				a = p.recoverMailbox(true)
+			}
+			if a != nil {
+				g.Members = append(g.Members, a)
+			}
+			p.consume(',')
+		}
+	}
+	p.pos = start
+	return p.parseMailbox()
+}
+
+// parseMailbox parses a name-addr or an addr-spec.
+func (p *addrParser) parseMailbox() (*Address, error) {
+	start := p.pos
+	words, err := p.parseWords()
+	if err != nil {
+		return nil, err
+	}
+	p.skipCFWS()
+	if p.peek('<') {
+		addr, err := p.parseAngleAddr()
+		if err != nil {
+			return nil, err
+		}
+		return &Address{Name: p.decodePhrase(words), Address: addr}, nil
+	}
+
+	// No angle brackets: this must be a bare addr-spec.
+	p.pos = start
+	addr, err := p.parseAddrSpec()
+	if err != nil {
+		return nil, err
+	}
+	// By an old convention,
+	// "local@domain (Name)" carries a display name in a comment.
+	name := p.skipCFWS()
+	return &Address{Name: name, Address: addr}, nil
+}
+
+func (p *addrParser) parseAngleAddr() (string, error) {
+	if !p.consume('<') {
+		return "", p.errorf("expected <")
+	}
+	p.skipCFWS()
+	if p.peek('@') {
+		// Skip an obsolete source route (obs-route): "@a,@b:".
+		i := strings.IndexByte(p.s[p.pos:], ':')
+		if i < 0 {
+			return "", p.errorf("bad source route")
+		}
+		p.pos += i + 1
+	}
+	addr, err := p.parseAddrSpec()
+	if err != nil {
+		return "", err
+	}
+	p.skipCFWS()
+	if !p.consume('>') {
+		return "", p.errorf("expected >")
+	}
+	return addr, nil
+}
+
+func (p *addrParser) parseAddrSpec() (string, error) {
+	p.skipCFWS()
+	var local string
+	if p.peek('"') {
+		q, err := p.parseQuotedString()
+		if err != nil {
+			return "", err
+		}
+		local = q
+	} else {
+		local = p.parseDotAtom()
+	}
+	if local == "" {
+		return "", p.errorf("missing local part")
+	}
+	p.skipCFWS()
+	if !p.consume('@') {
+		return "", p.errorf("expected @")
+	}
+	p.skipCFWS()
+	var domain string
+	if p.peek('[') {
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end < 0 {
+			return "", p.errorf("unterminated domain literal")
+		}
+		domain = p.s[p.pos : p.pos+end+1]
+		p.pos += end + 1
+	} else {
+		domain = p.parseDotAtom()
+	}
+	if domain == "" {
+		return "", p.errorf("missing domain")
+	}
+	return local + "@" + domain, nil
+}
+
+// parseDotAtom parses atoms separated by dots
+// (allowing the surrounding whitespace and comments of obs-local-part and obs-domain).
+func (p *addrParser) parseDotAtom() string {
+	var buf strings.Builder
+	for {
+		atom := p.parseAtom(false)
+		if atom == "" {
+			return buf.String()
+		}
+		buf.WriteString(atom)
+		save := p.pos
+		p.skipCFWS()
+		if !p.consume('.') {
+			p.pos = save
+			return buf.String()
+		}
+		buf.WriteByte('.')
+		p.skipCFWS()
+	}
+}
+
+// phraseWord is a word of a phrase:
+// an atom or the contents of a quoted string.
+type phraseWord struct {
+	text   string
+	quoted bool
+}
+
+// parseWords parses a (possibly empty) phrase.
+// As in obs-phrase,
+// atoms may contain dots.
+func (p *addrParser) parseWords() ([]phraseWord, error) {
+	var words []phraseWord
+	for {
+		p.skipCFWS()
+		if p.peek('"') {
+			q, err := p.parseQuotedString()
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, phraseWord{text: q, quoted: true})
+			continue
+		}
+		atom := p.parseAtom(true)
+		if atom == "" {
+			return words, nil
+		}
+		words = append(words, phraseWord{text: atom})
+	}
+}
+
+// parseAtom parses a run of atext characters
+// (including non-ASCII ones, per RFC6532),
+// and dots if dots is true.
+func (p *addrParser) parseAtom(dots bool) string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if !isAtext(c) && c < 0x80 && !(dots && c == '.') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// parseQuotedString parses a quoted string and returns its unquoted contents.
+func (p *addrParser) parseQuotedString() (string, error) {
+	if !p.consume('"') {
+		return "", p.errorf("expected quoted string")
+	}
+	var buf strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return buf.String(), nil
+		case '\\':
+			if p.pos < len(p.s) {
+				buf.WriteByte(p.s[p.pos])
+				p.pos++
+			}
+		case '\r', '\n':
+			// Folding whitespace.
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated quoted string")
+}
+
+// skipCFWS skips whitespace and comments.
+// It returns the text of the last comment skipped, if any.
+func (p *addrParser) skipCFWS() string {
+	var comment string
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '(':
+			start := p.pos
+			p.pos = skipComment(p.s, p.pos)
+			end := p.pos
+			if end > start+1 && p.s[end-1] == ')' {
+				end--
+			}
+			comment = strings.TrimSpace(p.s[start+1 : end])
+		default:
+			return comment
+		}
+	}
+	return comment
+}
+
+// skipComment returns the position following the comment
+// (which may nest) that begins at s[pos],
+// or len(s) if it is unterminated.
+func skipComment(s string, pos int) int {
+	depth := 0
+	for ; pos < len(s); pos++ {
+		switch s[pos] {
+		case '\\':
+			pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pos + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+func (p *addrParser) atEnd() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *addrParser) peek(c byte) bool {
+	return p.pos < len(p.s) && p.s[p.pos] == c
+}
+
+func (p *addrParser) consume(c byte) bool {
+	if p.peek(c) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// decodePhrase joins the words of a phrase with spaces,
+// decoding any that are RFC2047 encoded-words
+// (and removing the whitespace between adjacent ones).
+// In lenient mode,
+// it also decodes encoded-words in quoted strings
+// and text that is not valid UTF-8.
+func (p *addrParser) decodePhrase(words []phraseWord) string {
+	if p.lenient {
+		words = p.splitQuotedWords(words)
+	}
+	dec := mime.WordDecoder{CharsetReader: charsetReader}
+	var (
+		buf         strings.Builder
+		prevEncoded bool
+	)
+	for i, w := range words {
+		text, encoded := w.text, false
+		if p.lenient && !utf8.ValidString(text) {
+			text = p.decode8bit(text)
+		}
+		if (!w.quoted || p.lenient) && strings.HasPrefix(text, "=?") && strings.HasSuffix(text, "?=") {
+			if d, err := dec.Decode(text); err == nil {
+				if w.quoted {
+					p.warn(WarnAddressQuotedEncodedWord, "decoding %q in quoted string", text)
+				}
+				text, encoded = d, true
+			}
+		}
+		if i > 0 && !(encoded && prevEncoded) {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(text)
+		prevEncoded = encoded
+	}
+	return buf.String()
+}
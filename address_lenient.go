@@ -0,0 +1,289 @@
+package rmime
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ParseAddressListLenient is like ParseAddressList,
+// but recovers what it can from malformed input,
+// as commonly found in real-world messages,
+// instead of failing.
+// It returns a Warning for each repair it makes.
+//
+// The repairs are:
+//   - Special characters (such as commas and @) in an unquoted display name
+//     are taken as part of the name
+//     (WarnAddressSpecials).
+//   - An address lacking one or both angle brackets
+//     is recognized by its @
+//     (WarnAddressAngleBrackets).
+//   - Text following an address
+//     and list elements containing no address
+//     are ignored
+//     (WarnAddressJunk).
+//   - A malformed addr-spec is taken as is, less any whitespace,
+//     and a group with no closing semicolon
+//     is closed at the end of the input
+//     (WarnAddressSyntax).
+//   - A display name that is not valid UTF-8
+//     is decoded as Windows-1252
+//     (WarnAddress8bit).
+//   - RFC2047 encoded-words inside quoted strings are decoded
+//     (WarnAddressQuotedEncodedWord).
+//
+// Raw UTF-8 in display names is not a repair:
+// it is permitted by RFC6532
+// and accepted by ParseAddressList too.
+// Input that ParseAddressList accepts,
+// and that needs none of the repairs above,
+// produces the same result from both functions.
+func ParseAddressListLenient(s string) (AddressList, []*Warning) {
+	p := &addrParser{s: s, lenient: true}
+	list, _ := p.parseAddressList() // never fails in lenient mode
+	return list, p.warnings
+}
+
+func (p *addrParser) warn(kind WarningKind, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, &Warning{Kind: kind, Detail: fmt.Sprintf(format, args...)})
+}
+
+// recoverMailbox makes a best-effort mailbox
+// from the list element that begins at p.pos,
+// advancing p.pos to the end of it.
+// An element containing no @
+// is presumed to be the first part of a display name containing a comma,
+// and is joined with the elements that follow,
+// up to one that does contain an @.
+// The result is nil if no address can be found.
+func (p *addrParser) recoverMailbox(inGroup bool) *Address {
+	start := p.pos
+	end := p.elementEnd(start, inGroup)
+	for !strings.Contains(p.s[start:end], "@") && end < len(p.s) && p.s[end] == ',' {
+		end = p.elementEnd(end+1, inGroup)
+	}
+	p.pos = end
+
+	text := strings.TrimSpace(p.s[start:end])
+	a := p.repairMailbox(text)
+	if a == nil {
+		p.warn(WarnAddressJunk, "ignoring %q: no address found", text)
+	}
+	return a
+}
+
+// skipJunk skips, with a warning,
+// the remainder of the current list element.
+func (p *addrParser) skipJunk(inGroup bool) {
+	end := p.elementEnd(p.pos, inGroup)
+	if junk := strings.TrimSpace(p.s[p.pos:end]); junk != "" {
+		p.warn(WarnAddressJunk, "ignoring %q after address", junk)
+	}
+	p.pos = end
+}
+
+// elementEnd returns the position of the comma
+// (or, in a group, the comma or semicolon)
+// that ends the list element containing s[pos],
+// or the end of the input.
+func (p *addrParser) elementEnd(pos int, inGroup bool) int {
+	for pos < len(p.s) {
+		switch p.s[pos] {
+		case ',':
+			return pos
+		case ';':
+			if inGroup {
+				return pos
+			}
+		case '"':
+			pos = skipQuoted(p.s, pos)
+			continue
+		case '(':
+			pos = skipComment(p.s, pos)
+			continue
+		case '<':
+			if i := strings.IndexAny(p.s[pos+1:], "<>"); i >= 0 && p.s[pos+1+i] == '>' {
+				pos += i + 2
+				continue
+			}
+		case '[':
+			if i := strings.IndexAny(p.s[pos+1:], "[]"); i >= 0 && p.s[pos+1+i] == ']' {
+				pos += i + 2
+				continue
+			}
+		}
+		pos++
+	}
+	return pos
+}
+
+// repairMailbox makes a best-effort mailbox from text,
+// a single list element.
+func (p *addrParser) repairMailbox(text string) *Address {
+	q := &addrParser{s: text, lenient: true}
+	a, err := q.parseMailbox()
+	if err == nil {
+		if q.skipCFWS(); q.atEnd() {
+			p.warnings = append(p.warnings, q.warnings...)
+			return a
+		}
+	}
+
+	i := lastUnquoted(text, '<')
+	if err == nil && i < 0 {
+		p.warnings = append(p.warnings, q.warnings...)
+		p.warn(WarnAddressJunk, "ignoring %q after address", text[q.pos:])
+		return a
+	}
+	if i >= 0 {
+		name, rest := text[:i], text[i+1:]
+		var junk string
+		if j := strings.IndexByte(rest, '>'); j >= 0 {
+			rest, junk = rest[:j], rest[j+1:]
+		} else {
+			p.warn(WarnAddressAngleBrackets, "missing > in %q", text)
+		}
+		addr := p.repairAddrSpec(rest)
+		if addr == "" {
+			return nil
+		}
+		if junk = strings.TrimSpace(junk); junk != "" {
+			p.warn(WarnAddressJunk, "ignoring %q after address", junk)
+		}
+		return &Address{Name: p.repairName(name), Address: addr}
+	}
+
+	// No angle brackets.
+	// Take the last word containing an @ as the address,
+	// and the words preceding it as the display name.
+	fields := strings.Fields(text)
+	k := len(fields) - 1
+	for ; k >= 0; k-- {
+		if strings.Contains(fields[k], "@") {
+			break
+		}
+	}
+	if k < 0 {
+		return nil
+	}
+	addr := p.repairAddrSpec(strings.Trim(fields[k], `"'<>()[],;:`))
+	if addr == "" {
+		return nil
+	}
+	if k > 0 {
+		p.warn(WarnAddressAngleBrackets, "missing angle brackets around %q", addr)
+	}
+	if k < len(fields)-1 {
+		p.warn(WarnAddressJunk, "ignoring %q after address", strings.Join(fields[k+1:], " "))
+	}
+	return &Address{Name: p.repairName(strings.Join(fields[:k], " ")), Address: addr}
+}
+
+// repairAddrSpec makes a best-effort addr-spec from s.
+// The result is empty if s contains no @.
+func (p *addrParser) repairAddrSpec(s string) string {
+	s = strings.TrimSpace(s)
+	q := &addrParser{s: s}
+	if addr, err := q.parseAddrSpec(); err == nil {
+		if q.skipCFWS(); q.atEnd() {
+			return addr
+		}
+	}
+	if !strings.Contains(s, "@") {
+		return ""
+	}
+	addr := strings.Join(strings.Fields(s), "")
+	p.warn(WarnAddressSyntax, "malformed address %q", addr)
+	return addr
+}
+
+// repairName makes a best-effort display name from s.
+func (p *addrParser) repairName(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	q := &addrParser{s: s, lenient: true}
+	if words, err := q.parseWords(); err == nil {
+		if q.skipCFWS(); q.atEnd() {
+			name := q.decodePhrase(words)
+			p.warnings = append(p.warnings, q.warnings...)
+			return name
+		}
+	}
+	p.warn(WarnAddressSpecials, "unquoted special characters in display name %q", s)
+	var words []phraseWord
+	for _, f := range strings.Fields(strings.NewReplacer(`\"`, `"`, `"`, "").Replace(s)) {
+		words = append(words, phraseWord{text: f})
+	}
+	return p.decodePhrase(words)
+}
+
+// splitQuotedWords splits at whitespace
+// any quoted words containing what may be encoded-words,
+// so that decodePhrase can decode them.
+func (p *addrParser) splitQuotedWords(words []phraseWord) []phraseWord {
+	var result []phraseWord
+	for _, w := range words {
+		if w.quoted && strings.Contains(w.text, "=?") {
+			for _, f := range strings.Fields(w.text) {
+				result = append(result, phraseWord{text: f, quoted: true})
+			}
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// decode8bit decodes text,
+// which is not valid UTF-8,
+// as Windows-1252,
+// the most common charset of such text in headers.
+func (p *addrParser) decode8bit(text string) string {
+	p.warn(WarnAddress8bit, "decoding %q as windows-1252", text)
+	d, err := charmap.Windows1252.NewDecoder().String(text)
+	if err != nil {
+		return strings.ToValidUTF8(text, string(utf8.RuneError))
+	}
+	return d
+}
+
+// skipQuoted returns the position following the quoted string
+// that begins at s[pos],
+// or len(s) if it is unterminated.
+func skipQuoted(s string, pos int) int {
+	for pos++; pos < len(s); pos++ {
+		switch s[pos] {
+		case '\\':
+			pos++
+		case '"':
+			return pos + 1
+		}
+	}
+	return len(s)
+}
+
+// lastUnquoted returns the index of the last c in s
+// outside quoted strings and comments,
+// or -1.
+func lastUnquoted(s string, c byte) int {
+	result := -1
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '"':
+			i = skipQuoted(s, i)
+			continue
+		case '(':
+			i = skipComment(s, i)
+			continue
+		case c:
+			result = i
+		}
+		i++
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package rmime
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAddressListLenient(t *testing.T) {
+	cases := []struct {
+		inp   string
+		want  AddressList
+		kinds []WarningKind
+	}{{
+		inp: `"Public, John Q." <jq@example.com>, Mary Smith <mary@x.test>`,
+		want: AddressList{
+			&Address{Name: "Public, John Q.", Address: "jq@example.com"},
+			&Address{Name: "Mary Smith", Address: "mary@x.test"},
+		},
+	}, {
+		inp: "Public, John Q. <jq@example.com>, Mary Smith <mary@x.test>",
+		want: AddressList{
+			&Address{Name: "Public, John Q.", Address: "jq@example.com"},
+			&Address{Name: "Mary Smith", Address: "mary@x.test"},
+		},
+		kinds: []WarningKind{WarnAddressSpecials},
+	}, {
+		inp:   "John @ Home <john@example.com>",
+		want:  AddressList{&Address{Name: "John @ Home", Address: "john@example.com"}},
+		kinds: []WarningKind{WarnAddressSpecials},
+	}, {
+		inp:   "John Doe jdoe@example.com, a@example.com",
+		want:  AddressList{&Address{Name: "John Doe", Address: "jdoe@example.com"}, &Address{Address: "a@example.com"}},
+		kinds: []WarningKind{WarnAddressAngleBrackets},
+	}, {
+		inp:   "John Doe <jdoe@example.com",
+		want:  AddressList{&Address{Name: "John Doe", Address: "jdoe@example.com"}},
+		kinds: []WarningKind{WarnAddressAngleBrackets},
+	}, {
+		inp:   "John Doe <jdoe@example.com> (work) via list, a@example.com",
+		want:  AddressList{&Address{Name: "John Doe", Address: "jdoe@example.com"}, &Address{Address: "a@example.com"}},
+		kinds: []WarningKind{WarnAddressJunk},
+	}, {
+		inp:   "<jdoe@example.com>>",
+		want:  AddressList{&Address{Address: "jdoe@example.com"}},
+		kinds: []WarningKind{WarnAddressJunk},
+	}, {
+		inp:   "Zoë Brontë <zoe@example.com>",
+		want:  AddressList{&Address{Name: "Zoë Brontë", Address: "zoe@example.com"}},
+		kinds: nil,
+	}, {
+		inp:   "Andr\xe9 Pirard <pirard@example.com>",
+		want:  AddressList{&Address{Name: "André Pirard", Address: "pirard@example.com"}},
+		kinds: []WarningKind{WarnAddress8bit},
+	}, {
+		inp:   `"=?utf-8?q?Andr=C3=A9?= Pirard" <pirard@example.com>`,
+		want:  AddressList{&Address{Name: "André Pirard", Address: "pirard@example.com"}},
+		kinds: []WarningKind{WarnAddressQuotedEncodedWord},
+	}, {
+		inp:   "<Undisclosed Recipients>",
+		want:  nil,
+		kinds: []WarningKind{WarnAddressJunk},
+	}, {
+		inp: "Team: Ed Jones <ed@example.com>, Smith, Al <al@example.com>",
+		want: AddressList{&Group{Name: "Team", Members: []*Address{
+			{Name: "Ed Jones", Address: "ed@example.com"},
+			{Name: "Smith, Al", Address: "al@example.com"},
+		}}},
+		kinds: []WarningKind{WarnAddressSpecials, WarnAddressSyntax},
+	}, {
+		inp:   "Broken <jdoe @ example .com>",
+		want:  AddressList{&Address{Name: "Broken", Address: "jdoe@example.com"}},
+		kinds: nil,
+	}, {
+		inp:   "Broken <jdoe@exa mple.com>",
+		want:  AddressList{&Address{Name: "Broken", Address: "jdoe@example.com"}},
+		kinds: []WarningKind{WarnAddressSyntax},
+	}, {
+		inp:   `"\`,
+		want:  nil,
+		kinds: []WarningKind{WarnAddressJunk},
+	}, {
+		inp:   `a@example.com, "Bob \`,
+		want:  AddressList{&Address{Address: "a@example.com"}},
+		kinds: []WarningKind{WarnAddressJunk},
+	}, {
+		inp:   `Ann (\`,
+		want:  nil,
+		kinds: []WarningKind{WarnAddressJunk},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, warnings := ParseAddressListLenient(tc.inp)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			var kinds []WarningKind
+			for _, w := range warnings {
+				kinds = append(kinds, w.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tc.kinds) {
+				t.Errorf("got warnings %v, want kinds %v", warnings, tc.kinds)
+			}
+		})
+	}
+}
+
+func TestLenientSender(t *testing.T) {
+	const inp = `From: Doe, John jdoe@example.com
+To: Public, John Q. <jq@example.com>, <Undisclosed Recipients>
+Cc: mary@example.com
+
+`
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Sender(); got == nil || got.Name != "Doe, John" || got.Address != "jdoe@example.com" {
+		t.Errorf("got sender %v, want Doe, John <jdoe@example.com>", got)
+	}
+	var addrs []string
+	for _, a := range m.Recipients() {
+		addrs = append(addrs, a.Address)
+	}
+	if got, want := strings.Join(addrs, " "), "jq@example.com mary@example.com"; got != want {
+		t.Errorf("got recipients %s, want %s", got, want)
+	}
+	if _, err := m.From(); err == nil {
+		t.Error("got no error from strict From, want one")
+	}
+}
+
+func TestLenientSenderUnterminated(t *testing.T) {
+	const inp = "From: \"\\\nTo: \"\\\n\n"
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Sender(); got != nil {
+		t.Errorf("got sender %v, want nil", got)
+	}
+	if got := m.Recipients(); len(got) != 0 {
+		t.Errorf("got recipients %v, want none", got)
+	}
+}
@@ -0,0 +1,205 @@
+package rmime
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAddressList(t *testing.T) {
+	cases := []struct {
+		inp     string
+		want    AddressList
+		wantErr bool
+	}{{
+		inp:  "",
+		want: nil,
+	}, {
+		inp:  "a@example.com",
+		want: AddressList{&Address{Address: "a@example.com"}},
+	}, {
+		inp: `"Public, John Q." <jq@example.com>, Mary Smith <mary@x.test>`,
+		want: AddressList{
+			&Address{Name: "Public, John Q.", Address: "jq@example.com"},
+			&Address{Name: "Mary Smith", Address: "mary@x.test"},
+		},
+	}, {
+		inp:  "undisclosed-recipients:;",
+		want: AddressList{&Group{Name: "undisclosed-recipients"}},
+	}, {
+		inp: "A Group:Ed Jones <c@a.test>,joe@where.test,John <jdoe@one.test>;, solo@example.com",
+		want: AddressList{
+			&Group{Name: "A Group", Members: []*Address{
+				{Name: "Ed Jones", Address: "c@a.test"},
+				{Address: "joe@where.test"},
+				{Name: "John", Address: "jdoe@one.test"},
+			}},
+			&Address{Address: "solo@example.com"},
+		},
+	}, {
+		inp:  "=?utf-8?q?Andr=C3=A9?= =?utf-8?q?_Pirard?= <PIRARD@vm1.ulg.ac.be>",
+		want: AddressList{&Address{Name: "André Pirard", Address: "PIRARD@vm1.ulg.ac.be"}},
+	}, {
+		inp:  `"=?utf-8?q?not_decoded?=" <a@example.com>`,
+		want: AddressList{&Address{Name: "=?utf-8?q?not_decoded?=", Address: "a@example.com"}},
+	}, {
+		inp:  "Pete(A nice \\) chap) <pete(his account)@silly.test(his host)>",
+		want: AddressList{&Address{Name: "Pete", Address: "pete@silly.test"}},
+	}, {
+		inp:  "jdoe@example.org (John Doe)",
+		want: AddressList{&Address{Name: "John Doe", Address: "jdoe@example.org"}},
+	}, {
+		inp:  `"john doe"@example.org, <@route.test,@other.test:x@y.test>`,
+		want: AddressList{&Address{Address: "john doe@example.org"}, &Address{Address: "x@y.test"}},
+	}, {
+		inp:  "Zoë <zoë@example.com>, a@[192.0.2.1]",
+		want: AddressList{&Address{Name: "Zoë", Address: "zoë@example.com"}, &Address{Address: "a@[192.0.2.1]"}},
+	}, {
+		inp:  "a@example.com,, ,b@example.com",
+		want: AddressList{&Address{Address: "a@example.com"}, &Address{Address: "b@example.com"}},
+	}, {
+		inp:  "a@example.com (unterminated \\",
+		want: AddressList{&Address{Name: "unterminated \\", Address: "a@example.com"}},
+	}, {
+		inp:     "no at sign",
+		wantErr: true,
+	}, {
+		inp:     "Name <a@example.com",
+		wantErr: true,
+	}, {
+		inp:     "Group: a@example.com",
+		wantErr: true,
+	}, {
+		inp:     "a@example.com b@example.com",
+		wantErr: true,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := ParseAddressList(tc.inp)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSkipComment(t *testing.T) {
+	cases := []struct {
+		inp  string
+		want int
+	}{
+		{inp: "(a) b", want: 3},
+		{inp: "(a (b) \\) c) d", want: 12},
+		{inp: "(a", want: 2},
+		{inp: "(a\\", want: 3},
+		{inp: "(a (b)\\", want: 7},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			if got := skipComment(tc.inp, 0); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddressString(t *testing.T) {
+	cases := []struct {
+		item AddressItem
+		want string
+	}{
+		{item: &Address{Address: "a@example.com"}, want: "a@example.com"},
+		{item: &Address{Name: "Ann Example", Address: "a@example.com"}, want: "Ann Example <a@example.com>"},
+		{item: &Address{Name: "Example, Ann", Address: "a@example.com"}, want: `"Example, Ann" <a@example.com>`},
+		{item: &Address{Name: `Ann "The Man"`, Address: "a@example.com"}, want: `"Ann \"The Man\"" <a@example.com>`},
+		{item: &Address{Name: "Zoë", Address: "z@example.com"}, want: "=?utf-8?q?Zo=C3=AB?= <z@example.com>"},
+		{item: &Address{Address: "john doe@example.org"}, want: `"john doe"@example.org`},
+		{item: &Group{Name: "undisclosed-recipients"}, want: "undisclosed-recipients: ;"},
+		{item: &Group{Name: "Team", Members: []*Address{{Address: "a@x.test"}, {Name: "B", Address: "b@x.test"}}}, want: "Team: a@x.test, B <b@x.test>;"},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got := tc.item.String()
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+			list, err := ParseAddressList(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(list, AddressList{tc.item}) {
+				t.Errorf("round trip: got %v, want %v", list, tc.item)
+			}
+		})
+	}
+}
+
+func TestAddressFields(t *testing.T) {
+	const inp = `From: Ann <ann@example.com>, Bob <bob@example.com>
+Sender: Secretary <sec@example.com>
+Reply-To: list@example.com
+To: undisclosed-recipients:;
+Cc: Team: c@example.com, d@example.com;
+Resent-From: r@example.com
+Resent-Sender: rs@example.com
+Disposition-Notification-To: ann@example.com
+
+`
+	h, err := ReadHeader(strings.NewReader(inp), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := h.From()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(from) != 2 {
+		t.Errorf("got %d From addresses, want 2", len(from))
+	}
+	if got := h.Sender(); got == nil || got.Address != "ann@example.com" {
+		t.Errorf("got sender %v, want ann@example.com", got)
+	}
+	if got := h.SenderField(); got == nil || got.Address != "sec@example.com" {
+		t.Errorf("got sender field %v, want sec@example.com", got)
+	}
+	if got := h.ResentSender(); got == nil || got.Address != "rs@example.com" {
+		t.Errorf("got resent-sender %v, want rs@example.com", got)
+	}
+	to, err := h.To()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(to, AddressList{&Group{Name: "undisclosed-recipients"}}) {
+		t.Errorf("got To %v", to)
+	}
+	if got := len(h.Recipients()); got != 2 {
+		t.Errorf("got %d recipients, want 2", got)
+	}
+	for _, f := range []func() (AddressList, error){h.ReplyTo, h.ResentFrom, h.DispositionNotificationTo} {
+		if list, err := f(); err != nil || len(list) != 1 {
+			t.Errorf("got %v, %v", list, err)
+		}
+	}
+	if list, err := h.Bcc(); err != nil || list != nil {
+		t.Errorf("got Bcc %v, %v; want nil, nil", list, err)
+	}
+
+	want := `(NIL NIL (("Ann" NIL "ann" "example.com")("Bob" NIL "bob" "example.com")) (("Secretary" NIL "sec" "example.com")) ` +
+		`((NIL NIL "list" "example.com")) ((NIL NIL "undisclosed-recipients" NIL)(NIL NIL NIL NIL)) ` +
+		`((NIL NIL "Team" NIL)(NIL NIL "c" "example.com")(NIL NIL "d" "example.com")(NIL NIL NIL NIL)) NIL NIL NIL)`
+	if got := h.Envelope(); got != want {
+		t.Errorf("got envelope:\n%s\nwant:\n%s", got, want)
+	}
+}
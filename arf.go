@@ -0,0 +1,103 @@
+package rmime
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedbackReport is the type of a parsed message/feedback-report body part,
+// the machine-readable part of an abuse feedback report
+// in the Abuse Reporting Format,
+// or ARF (RFC5965).
+// Its Header holds the raw fields;
+// see Feedback for their parsed contents.
+type FeedbackReport struct {
+	Header *Header `json:"header"`
+}
+
+// Feedback holds the parsed fields of an abuse feedback report.
+// Absent fields have zero values.
+type Feedback struct {
+	FeedbackType          string     `json:"feedback_type"` // E.g. "abuse", "fraud", "virus", "other", "not-spam", "auth-failure". Canonicalized to lowercase.
+	UserAgent             string     `json:"user_agent"`
+	Version               string     `json:"version"`
+	OriginalEnvelopeID    string     `json:"original_envelope_id,omitempty"`
+	OriginalMailFrom      string     `json:"original_mail_from,omitempty"` // Without angle brackets.
+	OriginalRcptTo        []string   `json:"original_rcpt_to,omitempty"`   // Without angle brackets.
+	ArrivalDate           time.Time  `json:"arrival_date,omitempty"`
+	ReportingMTA          TypedValue `json:"reporting_mta,omitempty"`
+	SourceIP              netip.Addr `json:"source_ip,omitempty"`
+	SourcePort            int        `json:"source_port,omitempty"` // RFC6692.
+	Incidents             int        `json:"incidents,omitempty"`
+	ReportedDomain        []string   `json:"reported_domain,omitempty"`
+	ReportedURI           []string   `json:"reported_uri,omitempty"`
+	AuthenticationResults []string   `json:"authentication_results,omitempty"`
+
+	// These are for feedback type auth-failure (RFC6591).
+	AuthFailure       string `json:"auth_failure,omitempty"` // Canonicalized to lowercase.
+	DeliveryResult    string `json:"delivery_result,omitempty"`
+	DKIMDomain        string `json:"dkim_domain,omitempty"`
+	DKIMIdentity      string `json:"dkim_identity,omitempty"`
+	DKIMSelector      string `json:"dkim_selector,omitempty"`
+	IdentityAlignment string `json:"identity_alignment,omitempty"`
+}
+
+// Feedback parses the fields of fr.
+func (fr *FeedbackReport) Feedback() *Feedback {
+	h := fr.Header
+	if h == nil {
+		return &Feedback{}
+	}
+	result := &Feedback{
+		FeedbackType:          strings.ToLower(stripComments(h.fieldValue("Feedback-Type"))),
+		UserAgent:             h.fieldValue("User-Agent"),
+		Version:               stripComments(h.fieldValue("Version")),
+		OriginalEnvelopeID:    h.fieldValue("Original-Envelope-ID"),
+		OriginalMailFrom:      trimAngles(h.fieldValue("Original-Mail-From")),
+		ArrivalDate:           h.dateField("Arrival-Date"),
+		ReportingMTA:          parseTypedValue(h.fieldValue("Reporting-MTA")),
+		ReportedDomain:        h.fieldValues("Reported-Domain"),
+		ReportedURI:           h.fieldValues("Reported-URI"),
+		AuthenticationResults: h.fieldValues("Authentication-Results"),
+		AuthFailure:           strings.ToLower(stripComments(h.fieldValue("Auth-Failure"))),
+		DeliveryResult:        strings.ToLower(stripComments(h.fieldValue("Delivery-Result"))),
+		DKIMDomain:            h.fieldValue("DKIM-Domain"),
+		DKIMIdentity:          h.fieldValue("DKIM-Identity"),
+		DKIMSelector:          h.fieldValue("DKIM-Selector"),
+		IdentityAlignment:     h.fieldValue("Identity-Alignment"),
+	}
+	if result.ArrivalDate.IsZero() {
+		// Some older reports use the name from drafts of RFC5965.
+		result.ArrivalDate = h.dateField("Received-Date")
+	}
+	for _, rcpt := range h.fieldValues("Original-Rcpt-To") {
+		result.OriginalRcptTo = append(result.OriginalRcptTo, trimAngles(rcpt))
+	}
+
+	ip := stripComments(h.fieldValue("Source-IP"))
+	ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	if len(ip) > 5 && strings.EqualFold(ip[:5], "ipv6:") {
+		ip = ip[5:]
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		result.SourceIP = addr
+	}
+
+	if port, err := strconv.Atoi(stripComments(h.fieldValue("Source-Port"))); err == nil {
+		result.SourcePort = port
+	}
+	if incidents, err := strconv.Atoi(stripComments(h.fieldValue("Incidents"))); err == nil {
+		result.Incidents = incidents
+	}
+	return result
+}
+
+func trimAngles(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
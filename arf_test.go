@@ -0,0 +1,175 @@
+package rmime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedbackReport(t *testing.T) {
+	// Adapted from RFC5965, appendix B.
+	const inp = `From: <abusedesk@example.com>
+Date: Thu, 8 Mar 2005 17:40:36 EDT
+Subject: FW: Earn money
+To: <abuse@example.net>
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report;
+     boundary="part1_13d.2e68ed54_boundary"
+
+--part1_13d.2e68ed54_boundary
+Content-Type: text/plain; charset="US-ASCII"
+Content-Transfer-Encoding: 7bit
+
+This is an email abuse report for an email message received from IP
+192.0.2.1 on Thu, 8 Mar 2005 14:00:00 EDT.
+--part1_13d.2e68ed54_boundary
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+Original-Mail-From: <somespammer@example.net>
+Original-Rcpt-To: <user@example.com>
+Original-Rcpt-To: <user2@example.com>
+Arrival-Date: Thu, 8 Mar 2005 14:00:00 -0400
+Reporting-MTA: dns; mail.example.com
+Source-IP: 192.0.2.1
+Authentication-Results: mail.example.com;
+               spf=fail smtp.mail=somespammer@example.com
+Reported-Domain: example.net
+Reported-Uri: http://example.net/earn_money.html
+Reported-Uri: mailto:user@example.com
+Removal-Recipient: user@example.com
+
+--part1_13d.2e68ed54_boundary
+Content-Type: message/rfc822
+Content-Disposition: inline
+
+From: <somespammer@example.net>
+Received: from mailserver.example.net (mailserver.example.net
+        [192.0.2.1]) by example.com with ESMTP id M63d4137594e46;
+        Thu, 08 Mar 2005 14:00:00 -0400
+To: <Undisclosed Recipients>
+Subject: Earn money
+MIME-Version: 1.0
+Content-Type: text/plain
+Message-ID: 8787KJKJ3K4J3K4J3K4J3.mail@example.net
+Date: Thu, 02 Sep 2004 12:31:03 -0500
+
+Spam Spam Spam
+Spam Spam Spam
+Spam Spam Spam
+Spam Spam Spam
+--part1_13d.2e68ed54_boundary--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := m.Report()
+	if r == nil {
+		t.Fatal("no report")
+	}
+	if r.Type != "feedback-report" {
+		t.Errorf("got report type %s", r.Type)
+	}
+	fr := r.FeedbackReport()
+	if fr == nil {
+		t.Fatal("no feedback report")
+	}
+	if orig := r.OriginalMessage(); orig == nil || orig.Subject() != "Earn money" {
+		t.Errorf("got original message %v", orig)
+	}
+
+	got := fr.Feedback()
+	want := &Feedback{
+		FeedbackType:          "abuse",
+		UserAgent:             "SomeGenerator/1.0",
+		Version:               "1",
+		OriginalMailFrom:      "somespammer@example.net",
+		OriginalRcptTo:        []string{"user@example.com", "user2@example.com"},
+		ReportingMTA:          TypedValue{Type: "dns", Value: "mail.example.com"},
+		SourceIP:              netip.MustParseAddr("192.0.2.1"),
+		ReportedDomain:        []string{"example.net"},
+		ReportedURI:           []string{"http://example.net/earn_money.html", "mailto:user@example.com"},
+		AuthenticationResults: []string{"mail.example.com; spf=fail smtp.mail=somespammer@example.com"},
+	}
+	if wantDate := time.Date(2005, 3, 8, 18, 0, 0, 0, time.UTC); !got.ArrivalDate.Equal(wantDate) {
+		t.Errorf("got arrival date %s, want %s", got.ArrivalDate, wantDate)
+	}
+	got.ArrivalDate = time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFeedbackReportEncoded(t *testing.T) {
+	const report = "Feedback-Type: abuse\nUser-Agent: Some=Generator/1.0\nVersion: 1\n\n"
+
+	enc := base64.StdEncoding.EncodeToString([]byte(report))
+	cases := []struct {
+		encoding, body string
+	}{
+		{encoding: "base64", body: enc + "\n"},
+		{encoding: "quoted-printable", body: strings.ReplaceAll(report, "=", "=3D")},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			inp := "Content-Type: message/feedback-report\nContent-Transfer-Encoding: " + tc.encoding + "\n\n" + tc.body
+
+			m, err := ReadMessage(strings.NewReader(inp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fr, ok := m.B.(*FeedbackReport)
+			if !ok {
+				t.Fatalf("got body of type %T, want *FeedbackReport", m.B)
+			}
+			if got := fr.Feedback().UserAgent; got != "Some=Generator/1.0" {
+				t.Errorf("got user agent %q, want Some=Generator/1.0", got)
+			}
+
+			// Round trip.
+			buf := new(bytes.Buffer)
+			if _, err := m.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			m2, err := ReadMessage(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fr2, ok := m2.B.(*FeedbackReport)
+			if !ok {
+				t.Fatalf("after round trip, got body of type %T, want *FeedbackReport", m2.B)
+			}
+			if got := fr2.Feedback().UserAgent; got != "Some=Generator/1.0" {
+				t.Errorf("after round trip, got user agent %q, want Some=Generator/1.0", got)
+			}
+		})
+	}
+}
+
+func TestFeedbackReportTrailing(t *testing.T) {
+	const inp = "Content-Type: message/feedback-report\n\nFeedback-Type: abuse\nUser-Agent: SomeGenerator/1.0\nVersion: 1\n\nReceived: from somewhere\n"
+
+	if _, err := ReadMessage(strings.NewReader(inp)); err == nil {
+		t.Error("got no error in strict mode")
+	}
+
+	m, warnings, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{Lenient: true, PreserveRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnTrailingData {
+		t.Errorf("got warnings %v, want [%s]", warnings, WarnTrailingData)
+	}
+	if got := m.B.(*FeedbackReport).Feedback().FeedbackType; got != "abuse" {
+		t.Errorf("got feedback type %s, want abuse", got)
+	}
+}
@@ -0,0 +1,299 @@
+package rmime
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bobg/errors"
+)
+
+// AuthResults is the parsed value of an Authentication-Results field (RFC8601):
+// the verdicts of the authentication checks made by a host.
+type AuthResults struct {
+	// AuthServID identifies the host that made the checks,
+	// normally its domain name.
+	AuthServID string `json:"authserv_id"`
+
+	// Version is the version of the field's syntax,
+	// 1 if not given.
+	Version int `json:"version"`
+
+	// Results is empty if the field says "none".
+	Results []*AuthResult `json:"results,omitempty"`
+}
+
+// AuthResult is the result of one authentication check
+// in an Authentication-Results field.
+type AuthResult struct {
+	Method string `json:"method"` // E.g. "spf", "dkim", "dmarc", "arc", "iprev", "auth". Canonicalized to lowercase.
+	Result string `json:"result"` // E.g. "pass", "fail", "none". Canonicalized to lowercase.
+	Reason string `json:"reason,omitempty"`
+
+	// Props holds the properties of the check,
+	// keyed by ptype and property in lowercase,
+	// e.g. "smtp.mailfrom" or "header.d".
+	Props map[string]string `json:"props,omitempty"`
+}
+
+// ReceivedSPF is the parsed value of a Received-SPF field (RFC7208 section 9.1):
+// the verdict of an SPF check made by a host.
+type ReceivedSPF struct {
+	Result  string `json:"result"` // E.g. "pass", "fail", "softfail". Canonicalized to lowercase.
+	Comment string `json:"comment,omitempty"`
+
+	// Params holds the key-value pairs of the field,
+	// keyed in lowercase,
+	// e.g. "client-ip", "envelope-from", "helo", "receiver".
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// AuthResults parses the Authentication-Results fields of h,
+// in order.
+//
+// A message may contain fields added by any host,
+// including the sender,
+// so only those added by hosts within the recipient's own administrative domain
+// should be believed (RFC8601 section 5).
+// If trusted is not empty,
+// fields whose authserv-id is not in it
+// (compared case-insensitively),
+// or cannot be parsed,
+// are skipped.
+//
+// Malformed fields (among those not skipped) are skipped too,
+// so that one of them cannot hide the others;
+// the result then comes with an error for each of them,
+// joined as by errors.Join.
+func (h Header) AuthResults(trusted ...string) ([]*AuthResults, error) {
+	var (
+		result []*AuthResults
+		errs   []error
+	)
+	for _, v := range h.fieldValues("Authentication-Results") {
+		ar, err := ParseAuthResults(v)
+		var id string
+		if ar != nil {
+			id = ar.AuthServID
+		}
+		if !isTrusted(id, trusted) {
+			// Including fields whose authserv-id cannot be parsed.
+			continue
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "parsing Authentication-Results field %q", v))
+			continue
+		}
+		result = append(result, ar)
+	}
+	return result, errors.Join(errs...)
+}
+
+// ReceivedSPF parses the Received-SPF fields of h,
+// in order.
+// As with AuthResults,
+// if trusted is not empty,
+// fields are skipped unless their receiver parameter can be parsed and is in it,
+// and malformed fields are skipped with an error.
+func (h Header) ReceivedSPF(trusted ...string) ([]*ReceivedSPF, error) {
+	var (
+		result []*ReceivedSPF
+		errs   []error
+	)
+	for _, v := range h.fieldValues("Received-SPF") {
+		spf, err := ParseReceivedSPF(v)
+		var receiver string
+		if spf != nil {
+			receiver = spf.Params["receiver"]
+		}
+		if !isTrusted(receiver, trusted) {
+			// Including fields whose receiver cannot be parsed.
+			continue
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "parsing Received-SPF field %q", v))
+			continue
+		}
+		result = append(result, spf)
+	}
+	return result, errors.Join(errs...)
+}
+
+func isTrusted(id string, trusted []string) bool {
+	if len(trusted) == 0 {
+		return true
+	}
+	for _, t := range trusted {
+		if strings.EqualFold(id, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAuthResults parses v as the value of an Authentication-Results field.
+// On a syntax error after the authserv-id,
+// it returns an AuthResults containing the authserv-id
+// along with the error.
+func ParseAuthResults(v string) (*AuthResults, error) {
+	p := &addrParser{s: v}
+	p.skipCFWS()
+	id, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, p.errorf("missing authserv-id")
+	}
+	ar := &AuthResults{AuthServID: id, Version: 1}
+	p.skipCFWS()
+	if n := p.parseToken(""); n != "" {
+		if ar.Version, err = strconv.Atoi(n); err != nil {
+			return ar, p.errorf("bad version %q", n)
+		}
+		p.skipCFWS()
+	}
+	for {
+		p.skipCFWS()
+		if p.atEnd() {
+			return ar, nil
+		}
+		if !p.consume(';') {
+			return ar, p.errorf("expected semicolon")
+		}
+		p.skipCFWS()
+		if p.atEnd() {
+			// Tolerate a trailing semicolon.
+			return ar, nil
+		}
+		res, err := p.parseResInfo()
+		if err != nil {
+			return ar, err
+		}
+		if res != nil {
+			ar.Results = append(ar.Results, res)
+		}
+	}
+}
+
+// parseResInfo parses a method, result, reason, and properties,
+// or "none" (returning nil).
+func (p *addrParser) parseResInfo() (*AuthResult, error) {
+	method := strings.ToLower(p.parseToken("=/."))
+	if method == "" {
+		return nil, p.errorf("missing method")
+	}
+	p.skipCFWS()
+	if p.consume('/') {
+		// Ignore the method version.
+		p.skipCFWS()
+		p.parseToken("=")
+		p.skipCFWS()
+	}
+	if !p.consume('=') {
+		if method == "none" {
+			return nil, nil
+		}
+		return nil, p.errorf("expected = after method %s", method)
+	}
+	p.skipCFWS()
+	res := &AuthResult{Method: method, Result: strings.ToLower(p.parseToken(""))}
+	if res.Result == "" {
+		return nil, p.errorf("missing result for method %s", method)
+	}
+	for {
+		p.skipCFWS()
+		if p.atEnd() || p.peek(';') {
+			return res, nil
+		}
+		key := strings.ToLower(p.parseToken("=."))
+		if key == "" {
+			return nil, p.errorf("expected reason or property")
+		}
+		p.skipCFWS()
+		if key == "reason" && p.consume('=') {
+			p.skipCFWS()
+			reason, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			res.Reason = reason
+			continue
+		}
+		if !p.consume('.') {
+			return nil, p.errorf("expected . after %s", key)
+		}
+		p.skipCFWS()
+		prop := strings.ToLower(p.parseToken("="))
+		p.skipCFWS()
+		if prop == "" || !p.consume('=') {
+			return nil, p.errorf("bad property %s.%s", key, prop)
+		}
+		p.skipCFWS()
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if res.Props == nil {
+			res.Props = make(map[string]string)
+		}
+		res.Props[key+"."+prop] = val
+	}
+}
+
+// ParseReceivedSPF parses v as the value of a Received-SPF field.
+func ParseReceivedSPF(v string) (*ReceivedSPF, error) {
+	p := &addrParser{s: v}
+	p.skipCFWS()
+	spf := &ReceivedSPF{Result: strings.ToLower(p.parseToken(""))}
+	if spf.Result == "" {
+		return nil, p.errorf("missing result")
+	}
+	spf.Comment = p.skipCFWS()
+	for {
+		p.skipCFWS()
+		if p.consume(';') {
+			continue
+		}
+		if p.atEnd() {
+			return spf, nil
+		}
+		key := strings.ToLower(p.parseToken("="))
+		p.skipCFWS()
+		if key == "" || !p.consume('=') {
+			return spf, p.errorf("bad key-value pair")
+		}
+		p.skipCFWS()
+		val, err := p.parseValue()
+		if err != nil {
+			return spf, err
+		}
+		if spf.Params == nil {
+			spf.Params = make(map[string]string)
+		}
+		spf.Params[key] = val
+	}
+}
+
+// parseValue parses a quoted string (returning its contents)
+// or a token (see parseToken).
+func (p *addrParser) parseValue() (string, error) {
+	if p.peek('"') {
+		return p.parseQuotedString()
+	}
+	return p.parseToken(""), nil
+}
+
+// parseToken parses a run of characters other than whitespace,
+// parentheses, quotes, semicolons,
+// and the characters in stop.
+func (p *addrParser) parseToken(stop string) string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if strings.IndexByte(" \t\r\n()\";", c) >= 0 || strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
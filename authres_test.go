@@ -0,0 +1,192 @@
+package rmime
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthResults(t *testing.T) {
+	cases := []struct {
+		inp     string
+		want    *AuthResults
+		wantErr bool
+	}{{
+		inp:  "example.org 1; none",
+		want: &AuthResults{AuthServID: "example.org", Version: 1},
+	}, {
+		inp: "example.com; spf=pass smtp.mailfrom=example.net",
+		want: &AuthResults{AuthServID: "example.com", Version: 1, Results: []*AuthResult{
+			{Method: "spf", Result: "pass", Props: map[string]string{"smtp.mailfrom": "example.net"}},
+		}},
+	}, {
+		inp: `mx.google.com;
+       dkim=pass header.i=@example.com header.s=20161025 header.b=AbC+d/9=;
+       spf=softfail (google.com: domain of transitioning a@example.com does not designate 192.0.2.1 as permitted sender) smtp.mailfrom=a@example.com;
+       dmarc=FAIL (p=NONE sp=NONE dis=NONE) header.from=example.com;
+       arc=none`,
+		want: &AuthResults{AuthServID: "mx.google.com", Version: 1, Results: []*AuthResult{
+			{Method: "dkim", Result: "pass", Props: map[string]string{"header.i": "@example.com", "header.s": "20161025", "header.b": "AbC+d/9="}},
+			{Method: "spf", Result: "softfail", Props: map[string]string{"smtp.mailfrom": "a@example.com"}},
+			{Method: "dmarc", Result: "fail", Props: map[string]string{"header.from": "example.com"}},
+			{Method: "arc", Result: "none"},
+		}},
+	}, {
+		inp: `example.com; auth=pass (cram-md5) smtp.auth=sender@example.net;
+       iprev=fail policy.iprev=192.0.2.200 reason="no PTR record";
+       dkim/1=permerror reason = "bad signature" header . d = example.org`,
+		want: &AuthResults{AuthServID: "example.com", Version: 1, Results: []*AuthResult{
+			{Method: "auth", Result: "pass", Props: map[string]string{"smtp.auth": "sender@example.net"}},
+			{Method: "iprev", Result: "fail", Reason: "no PTR record", Props: map[string]string{"policy.iprev": "192.0.2.200"}},
+			{Method: "dkim", Result: "permerror", Reason: "bad signature", Props: map[string]string{"header.d": "example.org"}},
+		}},
+	}, {
+		inp: `"quoted.example" 2; spf=pass;`,
+		want: &AuthResults{AuthServID: "quoted.example", Version: 2, Results: []*AuthResult{
+			{Method: "spf", Result: "pass"},
+		}},
+	}, {
+		inp:     "",
+		wantErr: true,
+	}, {
+		inp:     "example.com; spf",
+		wantErr: true,
+	}, {
+		inp:     "example.com; spf=pass smtp",
+		wantErr: true,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := ParseAuthResults(tc.inp)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseReceivedSPF(t *testing.T) {
+	cases := []struct {
+		inp     string
+		want    *ReceivedSPF
+		wantErr bool
+	}{{
+		inp: `Pass (mybox.example.org: domain of myname@example.com designates 192.0.2.1 as permitted sender)
+       receiver=mybox.example.org; client-ip=192.0.2.1;
+       envelope-from="myname@example.com"; helo=foo.example.com;`,
+		want: &ReceivedSPF{
+			Result:  "pass",
+			Comment: "mybox.example.org: domain of myname@example.com designates 192.0.2.1 as permitted sender",
+			Params: map[string]string{
+				"receiver":      "mybox.example.org",
+				"client-ip":     "192.0.2.1",
+				"envelope-from": "myname@example.com",
+				"helo":          "foo.example.com",
+			},
+		},
+	}, {
+		inp:  "none",
+		want: &ReceivedSPF{Result: "none"},
+	}, {
+		inp: "softfail client-ip=2001:db8::1",
+		want: &ReceivedSPF{
+			Result: "softfail",
+			Params: map[string]string{"client-ip": "2001:db8::1"},
+		},
+	}, {
+		inp:     "",
+		wantErr: true,
+	}, {
+		inp:     "pass receiver",
+		wantErr: true,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := ParseReceivedSPF(tc.inp)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderAuthResults(t *testing.T) {
+	const inp = `Authentication-Results: ;;; junk
+Received-SPF: ;;; junk
+Received-SPF: pass receiver
+Authentication-Results: mx.example.com; spf=pass smtp.mailfrom=example.net
+Received-SPF: pass receiver=mx.example.com; client-ip=192.0.2.1
+Authentication-Results: forged.example; dkim=pass header.d=example.net
+Received-SPF: pass receiver=forged.example
+Authentication-Results: this is not parseable
+Subject: hello
+
+`
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ars, err := m.AuthResults("MX.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ars) != 1 || ars[0].AuthServID != "mx.example.com" || ars[0].Results[0].Method != "spf" {
+		t.Errorf("got %+v, want just the mx.example.com results", ars)
+	}
+
+	ars, err = m.AuthResults()
+	if err == nil {
+		t.Error("got no error for unrestricted AuthResults, want one")
+	} else if got := strings.Count(err.Error(), "parsing Authentication-Results field"); got != 2 {
+		t.Errorf("got %d errors for unrestricted AuthResults, want 2: %v", got, err)
+	}
+	var gotIDs []string
+	for _, ar := range ars {
+		gotIDs = append(gotIDs, ar.AuthServID)
+	}
+	if want := []string{"mx.example.com", "forged.example"}; !reflect.DeepEqual(gotIDs, want) {
+		t.Errorf("got authserv-ids %v, want %v", gotIDs, want)
+	}
+
+	spfs, err := m.ReceivedSPF("mx.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spfs) != 1 || spfs[0].Params["client-ip"] != "192.0.2.1" {
+		t.Errorf("got %+v, want just the mx.example.com result", spfs)
+	}
+
+	spfs, err = m.ReceivedSPF()
+	if err == nil {
+		t.Error("got no error for unrestricted ReceivedSPF, want one")
+	}
+	var gotReceivers []string
+	for _, spf := range spfs {
+		gotReceivers = append(gotReceivers, spf.Params["receiver"])
+	}
+	if want := []string{"mx.example.com", "forged.example"}; !reflect.DeepEqual(gotReceivers, want) {
+		t.Errorf("got receivers %v, want %v", gotReceivers, want)
+	}
+}
@@ -1,18 +1,20 @@
 package rmime
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 )
 
 // ErrUnimplemented is the error indicating an unimplemented feature.
 var ErrUnimplemented = errors.New("unimplemented")
 
 // DeliveryStatus is the type of a parsed message/delivery-status body part.
-// TODO: further parse message/delivery-status bodies
-// (parse out specific fields).
+// Its fields hold the raw groups of header fields;
+// see PerMessage and PerRecipient for their parsed contents.
 type DeliveryStatus struct {
 	Message    *Header   `json:"message"`
 	Recipients []*Header `json:"recipients"`
@@ -23,32 +25,82 @@ type DeliveryStatus struct {
 // specified in the header:
 //
 //	message/rfc822:          *Message
+//	message/global:          *Message
+//	message/global-headers:  *Header
+//	message/external-body:   *ExternalBody
 //	message/delivery-status: *DeliveryStatus
+//	message/global-delivery-status:
+//	                         *DeliveryStatus
+//	message/disposition-notification:
+//	                         *DispositionNotification
+//	message/global-disposition-notification:
+//	                         *DispositionNotification
+//	message/feedback-report: *FeedbackReport
 //	multipart/*:             *Multipart
 //	*/*:                     string
+//
+// (Including message/partial, whose body is a fragment of a message;
+// see Reassemble.)
 func ReadBody(r Reader, header *Header) (interface{}, error) {
+	return newParser(nil).readBody(r, header)
+}
+
+func (ps *parser) readBody(r Reader, header *Header) (interface{}, error) {
 	switch header.MajorType() {
 	case "message":
-		switch header.MinorType() {
-		case "rfc822", "news": // message/news == message/rfc822 per RFC5537
-			return ReadMessage(r)
+		switch minor := header.MinorType(); minor {
+		case "rfc822", "news", "global": // message/news == message/rfc822 per RFC5537, message/global is its RFC6532 counterpart
+			r, err := transferDecode(r, header)
+			if err != nil {
+				return nil, err
+			}
+			return ps.readMessage(r)
+
+		case "global-headers":
+			// The header of a message, with UTF-8 permitted (see RFC6532).
+			r, err := transferDecode(ps.fieldsReader(r), header)
+			if err != nil {
+				return nil, err
+			}
+			h, r, err := ps.readFields(r, "text/plain", true)
+			if errors.Is(err, io.EOF) {
+				h, err = &Header{DefaultType: "text/plain"}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := ps.checkTrailing(r, header); err != nil {
+				return nil, err
+			}
+			return h, nil
 
 		case "external-body":
-			return nil, ErrUnimplemented
+			eb, err := ps.readExternalBody(r, header)
+			if errors.Is(err, errNoAccessType) && ps.opts.Lenient {
+				ps.warn(WarnUnknownType, "no access-type parameter for %s, reading as text", header.Type())
+				break
+			}
+			return eb, err
 
 		case "partial":
-			return nil, ErrUnimplemented
+			// A fragment of a message, which cannot be parsed on its own.
+			// See Reassemble.
 
-		case "delivery-status":
+		case "delivery-status", "global-delivery-status":
 			// A message-level set of header fields, followed by one or more
-			// per-recipient sets of header fields (see RFC 3464).
-			perMessage, err := ReadHeader(r, "text/plain")
+			// per-recipient sets of header fields (see RFC 3464, and RFC 6533 for the global variant).
+			r, err := transferDecode(ps.fieldsReader(r), header)
+			if err != nil {
+				return nil, err
+			}
+			perMessage, r, err := ps.readFields(r, "text/plain", true)
 			if err != nil {
 				return nil, err
 			}
 			var perRecipient []*Header
 			for {
-				h, err := ReadHeader(r, "text/plain")
+				var h *Header
+				h, r, err = ps.readFields(r, "text/plain", true)
 				if errors.Is(err, io.EOF) {
 					break
 				}
@@ -62,16 +114,96 @@ func ReadBody(r Reader, header *Header) (interface{}, error) {
 			}
 			return &DeliveryStatus{perMessage, perRecipient}, nil
 
+		case "disposition-notification", "global-disposition-notification":
+			// A single set of header fields (see RFC 8098, and RFC 6533 for the global variant).
+			r, err := transferDecode(ps.fieldsReader(r), header)
+			if err != nil {
+				return nil, err
+			}
+			h, r, err := ps.readFields(r, "text/plain", true)
+			if errors.Is(err, io.EOF) {
+				h, err = &Header{DefaultType: "text/plain"}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := ps.checkTrailing(r, header); err != nil {
+				return nil, err
+			}
+			return &DispositionNotification{Header: h}, nil
+
+		case "feedback-report":
+			// A single set of header fields (see RFC 5965).
+			r, err := transferDecode(ps.fieldsReader(r), header)
+			if err != nil {
+				return nil, err
+			}
+			h, r, err := ps.readFields(r, "text/plain", true)
+			if errors.Is(err, io.EOF) {
+				h, err = &Header{DefaultType: "text/plain"}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := ps.checkTrailing(r, header); err != nil {
+				return nil, err
+			}
+			return &FeedbackReport{Header: h}, nil
+
 		default:
-			return nil, fmt.Errorf("unknown message subtype %s", header.MinorType())
+			if ps.opts.Lenient {
+				ps.warn(WarnUnknownType, "reading unknown type %s as text", header.Type())
+				break
+			}
+			return nil, fmt.Errorf("unknown message subtype %s", minor)
 		}
 
 	case "multipart":
-		return readMultipart(r, header)
+		return ps.readMultipart(r, header)
 	}
+	return readString(ps.bodyReader(r))
+}
+
+// transferDecode undoes any base64 or quoted-printable encoding
+// of a message/* body that is to be parsed.
+// RFC6532 permits such encoding for message/global and its relatives.
+// (RFC2046 forbids it for message/rfc822 and the like,
+// but it is tolerated here.)
+func transferDecode(r Reader, header *Header) (Reader, error) {
+	switch header.Encoding() {
+	case "base64", "quoted-printable":
+		dec, err := DecodeBody(r, header)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReader(dec), nil
+	}
+	return r, nil
+}
+
+// checkTrailing reads whatever follows the header fields
+// of a body that should consist of nothing else.
+// Anything other than blank lines is an error,
+// or in lenient mode is discarded with a warning.
+func (ps *parser) checkTrailing(r Reader, header *Header) error {
+	rest, err := readString(r)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(rest) == "" {
+		return nil
+	}
+	if ps.opts.Lenient {
+		ps.warn(WarnTrailingData, "discarding %d bytes following the fields of %s", len(rest), header.Type())
+		return nil
+	}
+	return fmt.Errorf("unexpected data following the fields of %s", header.Type())
+}
+
+func readString(r io.Reader) (string, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	return string(b), nil
 }
@@ -0,0 +1,271 @@
+package rmime
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// multipartReader splits the body of a multipart/* part into the
+// pieces separated by its boundary lines:
+// the preamble, each subpart, and (after the final boundary) the postamble.
+// It detects boundaries on the fly
+// and never buffers more than a fixed-size window of its input.
+//
+// As elsewhere in this package,
+// the newline preceding a boundary line is considered part of the preceding piece.
+type multipartReader struct {
+	br             *bufio.Reader
+	dashBoundary   []byte // "--" + boundary
+	nlDashBoundary []byte // "\n--" + boundary
+	cur            *partReader
+
+	// If the input ends before the final boundary,
+	// a lenient multipartReader behaves as if the final boundary appeared there
+	// (setting the truncated flag of the current partReader).
+	lenient bool
+
+	// Whether to record the text of each boundary line (see partReader.delim).
+	keepDelims bool
+
+	// The offset of the input within the parser's input (-1 if unknown),
+	// and the number of bytes consumed from it.
+	start, consumed int64
+}
+
+// Large enough to hold any legal boundary line (at most 70 chars plus
+// delimiters, RFC2046) with plenty of room to spare.
+const multipartBufSize = 4096
+
+func newMultipartReader(r io.Reader, boundary string) *multipartReader {
+	return &multipartReader{
+		br:             bufio.NewReaderSize(r, multipartBufSize),
+		dashBoundary:   []byte("--" + boundary),
+		nlDashBoundary: []byte("\n--" + boundary),
+		start:          -1,
+	}
+}
+
+// nextPart returns a reader over the next piece of the multipart body.
+// Any unread portion of the previous piece is skipped.
+func (mr *multipartReader) nextPart() (*partReader, error) {
+	if mr.cur != nil {
+		if _, err := io.Copy(io.Discard, mr.cur); err != nil {
+			return nil, err
+		}
+	}
+	mr.cur = &partReader{mr: mr, atLineStart: true}
+	return mr.cur, nil
+}
+
+// rest returns a reader over the input following the final boundary.
+func (mr *multipartReader) rest() io.Reader {
+	return restReader{mr}
+}
+
+type restReader struct {
+	mr *multipartReader
+}
+
+func (rr restReader) Read(buf []byte) (int, error) {
+	n, err := rr.mr.br.Read(buf)
+	rr.mr.consumed += int64(n)
+	return n, err
+}
+
+func (mr *multipartReader) offset() int64 {
+	if mr.start < 0 {
+		return -1
+	}
+	return mr.start + mr.consumed
+}
+
+// partReader reads one piece of a multipart body,
+// reporting io.EOF at the next boundary line.
+// If the input ends before a boundary line is found,
+// it reports io.ErrUnexpectedEOF
+// (unless its multipartReader is lenient).
+type partReader struct {
+	mr *multipartReader
+
+	avail       int    // number of bytes known to precede the next boundary
+	atLineStart bool   // whether the next unread byte begins a line
+	done        bool   // whether the boundary line has been consumed
+	final       bool   // whether that boundary line was the final one
+	truncated   bool   // whether the input ended with no boundary line (lenient mode only)
+	end         int64  // when done, the value of mr.consumed before the boundary line
+	delim       []byte // when done, the boundary line, if mr.keepDelims
+	err         error
+}
+
+// Read implements io.Reader.
+func (p *partReader) Read(buf []byte) (int, error) {
+	if err := p.prepare(); err != nil {
+		return 0, err
+	}
+	if len(buf) > p.avail {
+		buf = buf[:p.avail]
+	}
+	n, err := p.mr.br.Read(buf)
+	p.mr.consumed += int64(n)
+	p.avail -= n
+	if n > 0 {
+		p.atLineStart = buf[n-1] == '\n'
+	}
+	return n, err
+}
+
+// ReadByte implements io.ByteReader.
+func (p *partReader) ReadByte() (byte, error) {
+	if err := p.prepare(); err != nil {
+		return 0, err
+	}
+	c, err := p.mr.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p.mr.consumed++
+	p.avail--
+	p.atLineStart = c == '\n'
+	return c, nil
+}
+
+// offset reports the position of p in the parser's input.
+// Once p is done,
+// this is the position of its boundary line
+// (which p has consumed).
+func (p *partReader) offset() int64 {
+	if p.done && !p.truncated {
+		if p.mr.start < 0 {
+			return -1
+		}
+		return p.mr.start + p.end
+	}
+	return p.mr.offset()
+}
+
+func (p *partReader) prepare() error {
+	for p.avail == 0 {
+		if p.done {
+			return io.EOF
+		}
+		if p.err != nil {
+			return p.err
+		}
+		if err := p.fill(); err != nil {
+			p.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// fill examines the buffered input to determine how much of it
+// precedes the next boundary line.
+// If the input is positioned at a boundary line,
+// fill consumes it and marks p done.
+func (p *partReader) fill() error {
+	var (
+		mr   = p.mr
+		br   = mr.br
+		want = br.Buffered()
+	)
+	if want == 0 {
+		want = 1
+	}
+	for {
+		buf, err := br.Peek(want)
+		var eof bool
+		switch err {
+		case nil, bufio.ErrBufferFull:
+		case io.EOF:
+			eof = true
+		default:
+			if len(buf) == 0 {
+				return err
+			}
+			eof = true
+		}
+		if len(buf) == 0 {
+			if mr.lenient {
+				p.done, p.final, p.truncated = true, true, true
+				return nil
+			}
+			return io.ErrUnexpectedEOF
+		}
+
+		if p.atLineStart {
+			match, final, n, more := mr.matchBoundary(buf, eof)
+			if more && len(buf) < br.Size() {
+				want = len(buf) + 1
+				continue
+			}
+			if match {
+				p.end = mr.consumed
+				if mr.keepDelims {
+					p.delim = append([]byte(nil), buf[:n]...)
+				}
+				if _, err := br.Discard(n); err != nil {
+					return err
+				}
+				mr.consumed += int64(n)
+				p.done, p.final = true, final
+				return nil
+			}
+		}
+
+		if i := bytes.Index(buf, mr.nlDashBoundary); i >= 0 {
+			p.avail = i + 1
+			return nil
+		}
+
+		// A boundary might begin after a newline near the end of the buffer.
+		// Stop just after that newline so the next fill can decide.
+		tail := max(0, len(buf)-len(mr.nlDashBoundary)+1)
+		if j := bytes.LastIndexByte(buf[tail:], '\n'); j >= 0 {
+			p.avail = tail + j + 1
+		} else {
+			p.avail = len(buf)
+		}
+		return nil
+	}
+}
+
+// matchBoundary tells whether buf begins with a boundary line.
+// If so, it also reports whether it's the final boundary
+// and the length of the line including its terminating newline.
+// If buf is too short to tell, it returns more==true.
+// A boundary line may be terminated by the end of the input (eof==true).
+func (mr *multipartReader) matchBoundary(buf []byte, eof bool) (match, final bool, n int, more bool) {
+	db := mr.dashBoundary
+	if len(buf) < len(db) {
+		return false, false, 0, !eof && bytes.HasPrefix(db, buf)
+	}
+	if !bytes.HasPrefix(buf, db) {
+		return false, false, 0, false
+	}
+	rest := buf[len(db):]
+	line := rest
+	if k := bytes.IndexByte(rest, '\n'); k >= 0 {
+		line = rest[:k]
+		n = len(db) + k + 1
+	} else if !eof {
+		return false, false, 0, true
+	} else {
+		n = len(buf)
+	}
+	if bytes.HasPrefix(line, []byte("--")) {
+		final = true
+		line = line[2:]
+	}
+	// allow only LWSP and \r
+	for _, c := range line {
+		switch c {
+		case ' ', '\f', '\r', '\t', '\v':
+			// ignore
+		default:
+			return false, false, 0, false
+		}
+	}
+	return true, final, n, false
+}
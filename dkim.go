@@ -0,0 +1,574 @@
+package rmime
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bobg/errors"
+)
+
+// DKIMResolver looks up the DNS TXT records holding DKIM public keys
+// (RFC6376 section 3.6.2).
+// A *net.Resolver is a DKIMResolver;
+// see also DKIMKeyMap.
+type DKIMResolver interface {
+	// LookupTXT returns the TXT records for the given name,
+	// e.g. "selector._domainkey.example.com",
+	// each one's strings concatenated.
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DKIMKeyMap is a DKIMResolver that looks up key records in a map,
+// for testing and offline use.
+// Keys are names like "selector._domainkey.example.com",
+// values are key records like "v=DKIM1; k=rsa; p=MIIBIjANBg...".
+type DKIMKeyMap map[string]string
+
+// LookupTXT implements DKIMResolver.
+// A missing name produces a *net.DNSError with IsNotFound set.
+func (m DKIMKeyMap) LookupTXT(_ context.Context, name string) ([]string, error) {
+	rec, ok := m[strings.ToLower(strings.TrimSuffix(name, "."))]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return []string{rec}, nil
+}
+
+// DKIMVerifier checks the DKIM-Signature fields of messages (RFC6376).
+// The zero value is ready to use.
+type DKIMVerifier struct {
+	// Resolver looks up public keys.
+	// If nil, net.DefaultResolver is used.
+	Resolver DKIMResolver
+
+	// Now, if not nil, supplies the current time,
+	// for checking signature expiration.
+	Now func() time.Time
+}
+
+// Values for DKIMResult.Result,
+// as used in Authentication-Results fields (RFC8601 section 2.7.1).
+const (
+	DKIMPass      = "pass"
+	DKIMFail      = "fail"
+	DKIMPermError = "permerror"
+	DKIMTempError = "temperror"
+)
+
+// Errors reported in DKIMResult.Err
+// (along with others describing malformed signatures and keys).
+var (
+	ErrDKIMBodyHash  = errors.New("body hash does not match")
+	ErrDKIMSignature = errors.New("signature does not verify")
+	ErrDKIMExpired   = errors.New("signature expired")
+	ErrDKIMNoKey     = errors.New("no key for signature")
+)
+
+// DKIMResult is the outcome of checking one DKIM-Signature field.
+type DKIMResult struct {
+	Result string `json:"result"` // One of DKIMPass, DKIMFail, DKIMPermError, DKIMTempError.
+	Err    error  `json:"-"`      // Why Result is not DKIMPass.
+
+	Domain    string `json:"domain,omitempty"`    // The d= tag: the signing domain.
+	Selector  string `json:"selector,omitempty"`  // The s= tag.
+	Identity  string `json:"identity,omitempty"`  // The i= tag, or "@" plus Domain.
+	Algorithm string `json:"algorithm,omitempty"` // The a= tag, e.g. "rsa-sha256".
+
+	// BodyLength is the value of the l= tag,
+	// or -1 if there is none.
+	// When it is not -1,
+	// any part of the body after that many bytes (of canonicalized body)
+	// is not covered by the signature.
+	BodyLength int64 `json:"body_length"`
+
+	Timestamp  time.Time `json:"timestamp,omitempty"`  // The t= tag, if any.
+	Expiration time.Time `json:"expiration,omitempty"` // The x= tag, if any.
+
+	Field *Field `json:"-"`
+}
+
+// Verify checks each DKIM-Signature field in m,
+// returning a result for each one,
+// in the order the fields appear.
+// A message with no signatures produces no results.
+//
+// Verification uses the wire format of m (see Encoder).
+// If m was parsed with ParseOptions.PreserveRaw,
+// that is exactly the input,
+// as required for the "simple" canonicalizations;
+// otherwise the header fields and body are reconstructed
+// (see Part.WriteTo),
+// which normally suffices for "relaxed" ones.
+//
+// The error is for failures other than those of verification,
+// which are reported in the results.
+func (v *DKIMVerifier) Verify(ctx context.Context, m *Message) ([]*DKIMResult, error) {
+	var sigs []*Field
+	for _, f := range m.Fields {
+		if strings.EqualFold(strings.TrimSpace(f.N), "DKIM-Signature") {
+			sigs = append(sigs, f)
+		}
+	}
+	if len(sigs) == 0 {
+		return nil, nil
+	}
+
+	body, err := wireBytes(bodyWriter{p: (*Part)(m)})
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing body")
+	}
+
+	var results []*DKIMResult
+	for _, f := range sigs {
+		res := &DKIMResult{BodyLength: -1, Field: f}
+		if err := v.verify(ctx, m.Header, body, f, res); err != nil {
+			res.Err = err
+			if res.Result == "" {
+				res.Result = DKIMPermError
+			}
+		} else {
+			res.Result = DKIMPass
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// verify checks one signature.
+// On failure,
+// it sets res.Result if the error is other than a permerror.
+func (v *DKIMVerifier) verify(ctx context.Context, h *Header, body []byte, f *Field, res *DKIMResult) error {
+	tags, err := parseDKIMTags(f.Value())
+	if err != nil {
+		return err
+	}
+	for _, t := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[t]; !ok {
+			return errors.Errorf("missing %s= tag", t)
+		}
+	}
+	if tags["v"] != "1" {
+		return errors.Errorf("unknown version %s", tags["v"])
+	}
+	res.Domain = tags["d"]
+	res.Selector = tags["s"]
+	res.Algorithm = strings.ToLower(tags["a"])
+	res.Identity = tags["i"]
+	if res.Identity == "" {
+		res.Identity = "@" + res.Domain
+	}
+	at := strings.LastIndexByte(res.Identity, '@')
+	if at < 0 {
+		return errors.Errorf("bad identity %s", res.Identity)
+	}
+	idDomain := strings.ToLower(res.Identity[at+1:])
+	sigDomain := strings.ToLower(res.Domain)
+	if idDomain != sigDomain && !strings.HasSuffix(idDomain, "."+sigDomain) {
+		return errors.Errorf("identity %s is not in domain %s", res.Identity, res.Domain)
+	}
+
+	var signed []string
+	for _, name := range strings.Split(tags["h"], ":") {
+		if name = strings.TrimSpace(name); name != "" {
+			signed = append(signed, name)
+		}
+	}
+	hasFrom := false
+	for _, name := range signed {
+		if strings.EqualFold(name, "From") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		return errors.New("From field not signed")
+	}
+
+	if t, ok := tags["t"]; ok {
+		secs, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return errors.Errorf("bad t= tag %s", t)
+		}
+		res.Timestamp = time.Unix(secs, 0)
+	}
+	if x, ok := tags["x"]; ok {
+		secs, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return errors.Errorf("bad x= tag %s", x)
+		}
+		res.Expiration = time.Unix(secs, 0)
+		if !res.Timestamp.IsZero() && res.Expiration.Before(res.Timestamp) {
+			return errors.New("x= tag precedes t= tag")
+		}
+		now := time.Now
+		if v.Now != nil {
+			now = v.Now
+		}
+		if now().After(res.Expiration) {
+			return ErrDKIMExpired
+		}
+	}
+	if l, ok := tags["l"]; ok {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n < 0 {
+			return errors.Errorf("bad l= tag %s", l)
+		}
+		res.BodyLength = n
+	}
+
+	headerCanon, bodyCanon, err := parseDKIMCanon(tags["c"])
+	if err != nil {
+		return err
+	}
+	if q, ok := tags["q"]; ok && !strings.HasPrefix(strings.ToLower(q), "dns/txt") {
+		return errors.Errorf("unknown query method %s", q)
+	}
+	var keyType string
+	switch res.Algorithm {
+	case "rsa-sha256":
+		keyType = "rsa"
+	case "ed25519-sha256":
+		keyType = "ed25519"
+	default:
+		return errors.Errorf("unsupported algorithm %s", tags["a"])
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return errors.Wrap(err, "decoding b= tag")
+	}
+	bh, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil {
+		return errors.Wrap(err, "decoding bh= tag")
+	}
+
+	// Body hash.
+	cbody := canonicalizeBody(body, bodyCanon)
+	if res.BodyLength >= 0 {
+		if res.BodyLength > int64(len(cbody)) {
+			return errors.Errorf("l= tag %d exceeds body length %d", res.BodyLength, len(cbody))
+		}
+		cbody = cbody[:res.BodyLength]
+	}
+	sum := sha256.Sum256(cbody)
+	if !bytes.Equal(sum[:], bh) {
+		res.Result = DKIMFail
+		return ErrDKIMBodyHash
+	}
+
+	// Header hash.
+	hash := sha256.New()
+	if err := writeSignedFields(hash, h, signed, headerCanon); err != nil {
+		return err
+	}
+	sigField, err := wireBytes(f)
+	if err != nil {
+		return errors.Wrap(err, "serializing DKIM-Signature field")
+	}
+	hash.Write(bytes.TrimSuffix(canonicalizeField(stripDKIMSignature(sigField), headerCanon), []byte("\r\n")))
+	digest := hash.Sum(nil)
+
+	key, err := v.lookupKey(ctx, res, keyType)
+	if err != nil {
+		return err
+	}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 1024 {
+			return errors.Errorf("RSA key of %d bits is too short", key.N.BitLen())
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig); err != nil {
+			res.Result = DKIMFail
+			return ErrDKIMSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest, sig) {
+			res.Result = DKIMFail
+			return ErrDKIMSignature
+		}
+	}
+	return nil
+}
+
+// lookupKey fetches and parses the public key for a signature
+// (RFC6376 section 3.6.1).
+// A temporary DNS failure sets res.Result to DKIMTempError.
+func (v *DKIMVerifier) lookupKey(ctx context.Context, res *DKIMResult, keyType string) (crypto.PublicKey, error) {
+	resolver := v.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	name := res.Selector + "._domainkey." + res.Domain
+	recs, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, errors.Wrapf(ErrDKIMNoKey, "looking up %s", name)
+		}
+		res.Result = DKIMTempError
+		return nil, errors.Wrapf(err, "looking up %s", name)
+	}
+	if len(recs) == 0 {
+		return nil, errors.Wrapf(ErrDKIMNoKey, "looking up %s", name)
+	}
+
+	// Use the first record that parses.
+	var firstErr error
+	for _, rec := range recs {
+		key, err := parseDKIMKey(rec, keyType, res)
+		if err == nil {
+			return key, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, errors.Wrapf(firstErr, "in key record at %s", name)
+}
+
+func parseDKIMKey(rec, keyType string, res *DKIMResult) (crypto.PublicKey, error) {
+	tags, err := parseDKIMTags(rec)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, errors.Errorf("unknown version %s", v)
+	}
+	if k := strings.ToLower(tags["k"]); k != keyType && !(k == "" && keyType == "rsa") {
+		return nil, errors.Errorf("key type %s does not match algorithm %s", tags["k"], res.Algorithm)
+	}
+	if h, ok := tags["h"]; ok && !dkimListContains(h, "sha256") {
+		return nil, errors.New("key does not permit sha256")
+	}
+	if s, ok := tags["s"]; ok && !dkimListContains(s, "email") && !dkimListContains(s, "*") {
+		return nil, errors.New("key is not for email")
+	}
+	if t, ok := tags["t"]; ok && dkimListContains(t, "s") {
+		// Identity must be in exactly the signing domain.
+		if at := strings.LastIndexByte(res.Identity, '@'); !strings.EqualFold(res.Identity[at+1:], res.Domain) {
+			return nil, errors.Errorf("identity %s must be in exactly %s", res.Identity, res.Domain)
+		}
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, errors.New("missing p= tag")
+	}
+	if p == "" {
+		return nil, errors.New("key revoked")
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding p= tag")
+	}
+	if keyType == "ed25519" {
+		if len(der) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("ed25519 key of %d bytes", len(der))
+		}
+		return ed25519.PublicKey(der), nil
+	}
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if key, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+		return nil, errors.New("not an RSA key")
+	}
+	// Some publish the bare RSAPublicKey.
+	key, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing RSA key")
+	}
+	return key, nil
+}
+
+func dkimListContains(list, item string) bool {
+	for _, s := range strings.Split(list, ":") {
+		if strings.EqualFold(strings.TrimSpace(s), item) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDKIMTags parses a tag-list (RFC6376 section 3.2),
+// as in a DKIM-Signature field or a key record.
+// Whitespace is removed from the values of the b=, bh=, and p= tags,
+// which are base64,
+// and trimmed from the others.
+func parseDKIMTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue // trailing semicolon
+		}
+		name, val, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, errors.Errorf("bad tag %q", strings.TrimSpace(spec))
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := tags[name]; dup {
+			return nil, errors.Errorf("duplicate tag %s", name)
+		}
+		switch name {
+		case "b", "bh", "p":
+			val = strings.Join(strings.Fields(val), "")
+		default:
+			val = strings.TrimSpace(val)
+		}
+		tags[name] = val
+	}
+	return tags, nil
+}
+
+// DKIM canonicalization algorithms (RFC6376 section 3.4).
+const (
+	dkimSimple  = "simple"
+	dkimRelaxed = "relaxed"
+)
+
+// parseDKIMCanon parses the c= tag.
+func parseDKIMCanon(c string) (header, body string, err error) {
+	header, body, _ = strings.Cut(strings.ToLower(c), "/")
+	if header == "" {
+		header = dkimSimple
+	}
+	if body == "" {
+		body = dkimSimple
+	}
+	for _, alg := range []string{header, body} {
+		if alg != dkimSimple && alg != dkimRelaxed {
+			return "", "", errors.Errorf("unknown canonicalization %s", c)
+		}
+	}
+	return header, body, nil
+}
+
+// writeSignedFields writes to w the canonicalized fields of h named in signed
+// (RFC6376 section 5.4.2).
+// For a name appearing more than once in signed,
+// successive instances of the field are used,
+// from the bottom of the header up.
+// A name with no (remaining) field contributes nothing.
+func writeSignedFields(w io.Writer, h *Header, signed []string, canon string) error {
+	used := make(map[*Field]bool)
+	for _, name := range signed {
+		for i := len(h.Fields) - 1; i >= 0; i-- {
+			f := h.Fields[i]
+			if used[f] || !strings.EqualFold(strings.TrimSpace(f.N), name) {
+				continue
+			}
+			used[f] = true
+			b, err := wireBytes(f)
+			if err != nil {
+				return errors.Wrapf(err, "serializing %s field", name)
+			}
+			if _, err := w.Write(canonicalizeField(b, canon)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// canonicalizeField canonicalizes a field in wire format,
+// including its final CRLF
+// (RFC6376 sections 3.4.1 and 3.4.2).
+func canonicalizeField(b []byte, canon string) []byte {
+	if canon == dkimSimple {
+		return b
+	}
+	name, val, _ := bytes.Cut(b, []byte(":"))
+	name = bytes.ToLower(bytes.TrimRight(name, " \t"))
+	val = bytes.ReplaceAll(val, []byte("\r\n"), nil)
+	val = bytes.Join(bytes.FieldsFunc(val, func(r rune) bool { return r == ' ' || r == '\t' }), []byte(" "))
+	result := append(name, ':')
+	result = append(result, val...)
+	return append(result, '\r', '\n')
+}
+
+// canonicalizeBody canonicalizes a body in wire format
+// (RFC6376 sections 3.4.3 and 3.4.4).
+func canonicalizeBody(b []byte, canon string) []byte {
+	if canon == dkimRelaxed {
+		lines := bytes.SplitAfter(b, []byte("\r\n"))
+		var buf bytes.Buffer
+		for _, line := range lines {
+			content, hasEOL := bytes.CutSuffix(line, []byte("\r\n"))
+			fields := bytes.FieldsFunc(content, func(r rune) bool { return r == ' ' || r == '\t' })
+			if len(fields) > 0 && (content[0] == ' ' || content[0] == '\t') {
+				buf.WriteByte(' ')
+			}
+			buf.Write(bytes.Join(fields, []byte(" ")))
+			if hasEOL {
+				buf.WriteString("\r\n")
+			}
+		}
+		b = buf.Bytes()
+	}
+
+	// Remove empty lines at the end,
+	// and end with a single CRLF.
+	for bytes.HasSuffix(b, []byte("\r\n")) {
+		b = b[:len(b)-2]
+	}
+	if len(b) == 0 && canon == dkimRelaxed {
+		return nil
+	}
+	return append(b[:len(b):len(b)], '\r', '\n')
+}
+
+// stripDKIMSignature removes the value of the b= tag
+// from a DKIM-Signature field in wire format.
+func stripDKIMSignature(b []byte) []byte {
+	colon := bytes.IndexByte(b, ':')
+	if colon < 0 {
+		return b
+	}
+	var result []byte
+	result = append(result, b[:colon+1]...)
+	specs := bytes.Split(b[colon+1:], []byte(";"))
+	for i, spec := range specs {
+		if i > 0 {
+			result = append(result, ';')
+		}
+		if name, _, ok := bytes.Cut(spec, []byte("=")); ok && string(bytes.TrimSpace(name)) == "b" {
+			result = append(result, name...)
+			result = append(result, '=')
+			if i == len(specs)-1 {
+				// Keep the field's final CRLF.
+				result = append(result, '\r', '\n')
+			}
+			continue
+		}
+		result = append(result, spec...)
+	}
+	return result
+}
+
+// wireBytes returns the wire format of wt (see Encoder),
+// without line-length limits.
+func wireBytes(wt io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	e := &Encoder{MaxLineLength: -1, w: &buf}
+	err := e.Encode(wt)
+	return buf.Bytes(), err
+}
+
+// bodyWriter is an io.WriterTo for the body of a part.
+type bodyWriter struct {
+	p *Part
+}
+
+func (bw bodyWriter) WriteTo(w io.Writer) (int64, error) {
+	return bw.p.writeBody(w)
+}
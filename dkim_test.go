@@ -0,0 +1,163 @@
+package rmime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobg/errors"
+)
+
+// From RFC8463 appendix A.
+const dkimTestMessage = `DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;
+ d=football.example.com; i=@football.example.com;
+ q=dns/txt; s=brisbane; t=1528637909; h=from : to :
+ subject : date : message-id : from : subject : date;
+ bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;
+ b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus
+ Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==
+DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed;
+ d=football.example.com; i=@football.example.com;
+ q=dns/txt; s=test; t=1528637909; h=from : to : subject :
+ date : message-id : from : subject : date;
+ bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;
+ b=F45dVWDfMbQDGHJFlXUNB2HKfbCeLRyhDXgFpEL8GwpsRe0IeIixNTe3
+ DhCVlUrSjV4BwcVcOF6+FF3Zo9Rpo1tFOeS9mPYQTnGdaSGsgeefOsk2Jz
+ dA+L10TeYt9BgDfQNZtKdN1WO//KgIqXP7OdEFE4LjFYNcUxZQ4FADY+8=
+From: Joe SixPack <joe@football.example.com>
+To: Suzie Q <suzie@shopping.example.net>
+Subject: Is dinner ready?
+Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)
+Message-ID: <20030712040037.46341.5F8J@football.example.com>
+
+Hi.
+
+We lost the game.  Are you hungry yet?
+
+Joe.
+`
+
+var dkimTestKeys = DKIMKeyMap{
+	"brisbane._domainkey.football.example.com": "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+	"test._domainkey.football.example.com": "v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDkHlOQoBTzWR" +
+		"iGs5V6NpP3idY6Wk08a5qhdR6wy5bdOKb2jLQiY/J16JYi0Qvx/byYzCNb3W91y3FutAC" +
+		"DfzwQ/BC/e/8uBsCR+yz1Lxj+PL6lHvqMKrM3rG4hstT5QjvHO9PzoxZyVYLzBfO2EeC3" +
+		"Ip3G+2kryOTIKT+l/K4w3QIDAQAB",
+}
+
+func TestDKIMVerify(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(string) string
+		keys  DKIMKeyMap
+		want  []string
+		wantE []error
+	}{{
+		name: "intact",
+		want: []string{DKIMPass, DKIMPass},
+	}, {
+		name: "crlf",
+		edit: func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") },
+		want: []string{DKIMPass, DKIMPass},
+	}, {
+		name: "relaxed whitespace changes",
+		edit: func(s string) string {
+			s = strings.Replace(s, "Subject: Is dinner ready?", "subject:  Is   dinner ready?  ", 1)
+			return strings.Replace(s, "Hi.\n", "Hi.   \n", 1) + "\n\n"
+		},
+		want: []string{DKIMPass, DKIMPass},
+	}, {
+		name:  "body modified",
+		edit:  func(s string) string { return strings.Replace(s, "lost", "won", 1) },
+		want:  []string{DKIMFail, DKIMFail},
+		wantE: []error{ErrDKIMBodyHash, ErrDKIMBodyHash},
+	}, {
+		name:  "header modified",
+		edit:  func(s string) string { return strings.Replace(s, "Suzie Q", "Suzy Q", 1) },
+		want:  []string{DKIMFail, DKIMFail},
+		wantE: []error{ErrDKIMSignature, ErrDKIMSignature},
+	}, {
+		name:  "missing key",
+		keys:  DKIMKeyMap{"test._domainkey.football.example.com": dkimTestKeys["test._domainkey.football.example.com"]},
+		want:  []string{DKIMPermError, DKIMPass},
+		wantE: []error{ErrDKIMNoKey, nil},
+	}, {
+		name: "expired",
+		edit: func(s string) string {
+			return strings.Replace(s, "t=1528637909; h=from : to :", "t=1528637909; x=1528637910; h=from : to :", 1)
+		},
+		want:  []string{DKIMPermError, DKIMPass},
+		wantE: []error{ErrDKIMExpired, nil},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			inp := dkimTestMessage
+			if tc.edit != nil {
+				inp = tc.edit(inp)
+			}
+			m, _, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{PreserveRaw: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := tc.keys
+			if keys == nil {
+				keys = dkimTestKeys
+			}
+			v := &DKIMVerifier{Resolver: keys}
+			results, err := v.Verify(context.Background(), m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tc.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tc.want))
+			}
+			for i, res := range results {
+				if res.Result != tc.want[i] {
+					t.Errorf("result %d: got %s (%v), want %s", i, res.Result, res.Err, tc.want[i])
+				}
+				if tc.wantE != nil && tc.wantE[i] != nil && !errors.Is(res.Err, tc.wantE[i]) {
+					t.Errorf("result %d: got error %v, want %v", i, res.Err, tc.wantE[i])
+				}
+				if res.Domain != "football.example.com" {
+					t.Errorf("result %d: got domain %s, want football.example.com", i, res.Domain)
+				}
+			}
+		})
+	}
+}
+
+func TestDKIMCanonicalizeBody(t *testing.T) {
+	cases := []struct {
+		inp, simple, relaxed string
+	}{
+		{inp: "", simple: "\r\n", relaxed: ""},
+		{inp: "\r\n\r\n", simple: "\r\n", relaxed: ""},
+		{inp: "a  b \t\r\n c\r\n\r\n", simple: "a  b \t\r\n c\r\n", relaxed: "a b\r\n c\r\n"},
+		{inp: "x", simple: "x\r\n", relaxed: "x\r\n"},
+		{inp: "x\r\n \r\n\t\r\n", simple: "x\r\n \r\n\t\r\n", relaxed: "x\r\n"},
+	}
+	for _, tc := range cases {
+		if got := string(canonicalizeBody([]byte(tc.inp), dkimSimple)); got != tc.simple {
+			t.Errorf("simple %q: got %q, want %q", tc.inp, got, tc.simple)
+		}
+		if got := string(canonicalizeBody([]byte(tc.inp), dkimRelaxed)); got != tc.relaxed {
+			t.Errorf("relaxed %q: got %q, want %q", tc.inp, got, tc.relaxed)
+		}
+	}
+}
+
+func TestDKIMNoSignatures(t *testing.T) {
+	m, err := ReadMessage(strings.NewReader("From: a@example.com\n\nhi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := (&DKIMVerifier{Resolver: DKIMKeyMap{}, Now: time.Now}).Verify(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %d results, want 0", len(results))
+	}
+}
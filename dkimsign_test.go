@@ -0,0 +1,188 @@
+package rmime
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dkimSignTestMessage = `From: Joe SixPack <joe@football.example.com>
+To: Suzie Q <suzie@shopping.example.net>
+Subject: Is dinner ready?
+Date: Fri, 11 Jul 2003 21:00:37 -0700
+Message-ID: <20030712040037.46341.5F8J@football.example.com>
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary=xyz
+
+preamble  with   spaces
+--xyz
+Content-Type: text/plain
+
+We lost the game.
+Are you hungry yet?
+
+--xyz
+Content-Type: text/html
+
+<p>We lost the game.</p>
+--xyz--
+`
+
+func TestDKIMSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := DKIMKeyMap{
+		"rsa._domainkey.football.example.com": "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub),
+		"ed._domainkey.football.example.com":  "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub),
+	}
+
+	cases := []struct {
+		selector string
+		key      crypto.Signer
+		canon    string
+		headers  []string
+		identity string
+	}{
+		{selector: "rsa", key: rsaKey},
+		{selector: "ed", key: edKey},
+		{selector: "rsa", key: rsaKey, canon: "simple/simple"},
+		{selector: "ed", key: edKey, canon: "relaxed/simple", identity: "joe@sub.football.example.com"},
+		{selector: "rsa", key: rsaKey, canon: "simple", headers: []string{"From", "From", "Subject", "Reply-To"}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			m, err := ReadMessage(strings.NewReader(dkimSignTestMessage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &DKIMSigner{
+				Domain:           "football.example.com",
+				Selector:         tc.selector,
+				Key:              tc.key,
+				Headers:          tc.headers,
+				Canonicalization: tc.canon,
+				Identity:         tc.identity,
+				Expiration:       time.Hour,
+			}
+			if err := s.Sign(m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Fields[0].Name(); got != "DKIM-Signature" {
+				t.Fatalf("got first field %s, want DKIM-Signature", got)
+			}
+
+			v := &DKIMVerifier{Resolver: keys}
+			check := func(what string, m *Message, want string) {
+				t.Helper()
+				results, err := v.Verify(context.Background(), m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != 1 {
+					t.Fatalf("%s: got %d results, want 1", what, len(results))
+				}
+				if results[0].Result != want {
+					t.Errorf("%s: got %s (%v), want %s", what, results[0].Result, results[0].Err, want)
+				}
+			}
+
+			check("in memory", m, DKIMPass)
+
+			// Round trip through WriteTo.
+			buf := new(bytes.Buffer)
+			if _, err := m.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			m2, err := ReadMessage(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("after WriteTo", m2, DKIMPass)
+
+			// Round trip through wire format, preserving it exactly.
+			buf.Reset()
+			if err := NewEncoder(buf).Encode(m); err != nil {
+				t.Fatal(err)
+			}
+			m3, _, err := ReadMessageWithOptions(bytes.NewReader(buf.Bytes()), &ParseOptions{PreserveRaw: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("after Encode", m3, DKIMPass)
+
+			// Adding an unsigned field is harmless;
+			// changing a signed one is not.
+			m3.Add("X-Extra", "yes")
+			check("with unsigned field added", m3, DKIMPass)
+			m3.Set("Subject", "Is lunch ready?")
+			check("with signed field changed", m3, DKIMFail)
+		})
+	}
+}
+
+func TestDKIMSignErrors(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []*DKIMSigner{
+		{Domain: "example.com", Selector: "s", Key: edKey, Headers: []string{"Subject"}},
+		{Domain: "example.com", Selector: "s", Key: edKey, Identity: "joe@example.org"},
+		{Domain: "example.com", Selector: "s", Key: edKey, Canonicalization: "loose"},
+		{Domain: "example.com", Selector: "s"},
+	}
+	for i, s := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			m, err := ReadMessage(strings.NewReader("From: joe@example.com\nSubject: hi\n\nhi\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Sign(m); err == nil {
+				t.Error("got no error, want one")
+			}
+			if len(m.Fields) != 2 {
+				t.Errorf("got %d fields, want 2", len(m.Fields))
+			}
+		})
+	}
+}
+
+func TestDKIMSignRSAKeySize(t *testing.T) {
+	// Newer Go versions refuse to generate or use such a short key,
+	// but Sign must reject it before trying.
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: new(big.Int).SetBit(big.NewInt(1), 511, 1),
+			E: 65537,
+		},
+	}
+	m, err := ReadMessage(strings.NewReader("From: joe@example.com\n\nhi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&DKIMSigner{Domain: "example.com", Selector: "s", Key: key}).Sign(m)
+	if err == nil || !strings.Contains(err.Error(), "RSA key of 512 bits is too short") {
+		t.Errorf("got error %v, want key size error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package rmime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypedValue is the value of a field,
+// such as Final-Recipient or Diagnostic-Code,
+// that begins with a type
+// (an address-type like "rfc822", an MTA-name-type like "dns",
+// or a diagnostic-type like "smtp")
+// separated from the rest by a semicolon.
+type TypedValue struct {
+	Type  string `json:"type,omitempty"` // Canonicalized to lowercase.
+	Value string `json:"value,omitempty"`
+}
+
+func (tv TypedValue) String() string {
+	if tv.Type == "" {
+		return tv.Value
+	}
+	return tv.Type + "; " + tv.Value
+}
+
+func parseTypedValue(v string) TypedValue {
+	typ, val, ok := strings.Cut(v, ";")
+	if !ok {
+		return TypedValue{Value: strings.TrimSpace(v)}
+	}
+	return TypedValue{
+		Type:  strings.ToLower(strings.TrimSpace(typ)),
+		Value: strings.TrimSpace(val),
+	}
+}
+
+// StatusCode is an enhanced mail system status code,
+// class.subject.detail,
+// as defined in RFC3463.
+type StatusCode struct {
+	Class   int `json:"class"`   // 2 (success), 4 (persistent transient failure), or 5 (permanent failure).
+	Subject int `json:"subject"` // E.g. 1 for addressing status.
+	Detail  int `json:"detail"`
+}
+
+// ParseStatusCode parses a status code like "5.1.1".
+// Any trailing comment is ignored.
+func ParseStatusCode(s string) (StatusCode, error) {
+	s = stripComments(s)
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return StatusCode{}, fmt.Errorf("bad status code %q", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || len(p) > 3 {
+			return StatusCode{}, fmt.Errorf("bad status code %q", s)
+		}
+		nums[i] = n
+	}
+	switch nums[0] {
+	case 2, 4, 5:
+	default:
+		return StatusCode{}, fmt.Errorf("bad status code class in %q", s)
+	}
+	return StatusCode{Class: nums[0], Subject: nums[1], Detail: nums[2]}, nil
+}
+
+func (c StatusCode) String() string {
+	return fmt.Sprintf("%d.%d.%d", c.Class, c.Subject, c.Detail)
+}
+
+// IsZero tells whether c is the zero StatusCode,
+// which denotes an absent or unparseable status.
+func (c StatusCode) IsZero() bool {
+	return c == StatusCode{}
+}
+
+// IsSuccess tells whether c denotes success.
+func (c StatusCode) IsSuccess() bool {
+	return c.Class == 2
+}
+
+// IsTransient tells whether c denotes a persistent transient failure.
+func (c StatusCode) IsTransient() bool {
+	return c.Class == 4
+}
+
+// IsPermanent tells whether c denotes a permanent failure.
+func (c StatusCode) IsPermanent() bool {
+	return c.Class == 5
+}
+
+// MessageStatus holds the parsed per-message fields of a delivery status notification (RFC3464).
+// Absent fields have zero values.
+type MessageStatus struct {
+	OriginalEnvelopeID string     `json:"original_envelope_id,omitempty"`
+	ReportingMTA       TypedValue `json:"reporting_mta"`
+	DSNGateway         TypedValue `json:"dsn_gateway,omitempty"`
+	ReceivedFromMTA    TypedValue `json:"received_from_mta,omitempty"`
+	ArrivalDate        time.Time  `json:"arrival_date,omitempty"`
+}
+
+// RecipientStatus holds the parsed per-recipient fields of a delivery status notification (RFC3464).
+// Absent fields have zero values.
+type RecipientStatus struct {
+	OriginalRecipient TypedValue `json:"original_recipient,omitempty"`
+	FinalRecipient    TypedValue `json:"final_recipient"`
+	Action            string     `json:"action"` // "failed", "delayed", "delivered", "relayed", or "expanded".
+	Status            StatusCode `json:"status"`
+	RemoteMTA         TypedValue `json:"remote_mta,omitempty"`
+	DiagnosticCode    TypedValue `json:"diagnostic_code,omitempty"`
+	LastAttemptDate   time.Time  `json:"last_attempt_date,omitempty"`
+	FinalLogID        string     `json:"final_log_id,omitempty"`
+	WillRetryUntil    time.Time  `json:"will_retry_until,omitempty"`
+}
+
+// PerMessage parses the per-message fields of ds.
+func (ds *DeliveryStatus) PerMessage() *MessageStatus {
+	h := ds.Message
+	if h == nil {
+		return &MessageStatus{}
+	}
+	return &MessageStatus{
+		OriginalEnvelopeID: h.fieldValue("Original-Envelope-ID"),
+		ReportingMTA:       parseTypedValue(h.fieldValue("Reporting-MTA")),
+		DSNGateway:         parseTypedValue(h.fieldValue("DSN-Gateway")),
+		ReceivedFromMTA:    parseTypedValue(h.fieldValue("Received-From-MTA")),
+		ArrivalDate:        h.dateField("Arrival-Date"),
+	}
+}
+
+// PerRecipient parses the per-recipient fields of ds.
+func (ds *DeliveryStatus) PerRecipient() []*RecipientStatus {
+	var result []*RecipientStatus
+	for _, h := range ds.Recipients {
+		rs := &RecipientStatus{
+			OriginalRecipient: parseTypedValue(h.fieldValue("Original-Recipient")),
+			FinalRecipient:    parseTypedValue(h.fieldValue("Final-Recipient")),
+			Action:            strings.ToLower(stripComments(h.fieldValue("Action"))),
+			RemoteMTA:         parseTypedValue(h.fieldValue("Remote-MTA")),
+			DiagnosticCode:    parseTypedValue(h.fieldValue("Diagnostic-Code")),
+			LastAttemptDate:   h.dateField("Last-Attempt-Date"),
+			FinalLogID:        h.fieldValue("Final-Log-ID"),
+			WillRetryUntil:    h.dateField("Will-Retry-Until"),
+		}
+		rs.Status, _ = ParseStatusCode(h.fieldValue("Status"))
+		result = append(result, rs)
+	}
+	return result
+}
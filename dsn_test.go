@@ -0,0 +1,124 @@
+package rmime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveryStatus(t *testing.T) {
+	const inp = `Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Original-Envelope-Id: abc123
+Arrival-Date: Mon, 2 Jan 2006 15:04:05 -0700
+
+Original-Recipient: rfc822;Someone@example.org
+Final-Recipient: RFC822; someone@example.org
+Action: Failed (permanent)
+Status: 5.1.1 (user unknown)
+Remote-MTA: dns; mail.example.org
+Diagnostic-Code: smtp; 550 5.1.1 <someone@example.org>:
+ Recipient address rejected
+Last-Attempt-Date: Mon, 2 Jan 2006 15:05:05 -0700
+
+Final-Recipient: rfc822; other@example.org
+Action: delayed
+Status: 4.4.7
+Will-Retry-Until: Tue, 3 Jan 2006 15:04:05 -0700
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, ok := m.B.(*DeliveryStatus)
+	if !ok {
+		t.Fatalf("got body of type %T, want *DeliveryStatus", m.B)
+	}
+
+	loc := time.FixedZone("", -7*60*60)
+
+	gotMsg := ds.PerMessage()
+	wantMsg := &MessageStatus{
+		OriginalEnvelopeID: "abc123",
+		ReportingMTA:       TypedValue{Type: "dns", Value: "mx.example.com"},
+		ArrivalDate:        time.Date(2006, 1, 2, 15, 4, 5, 0, loc),
+	}
+	if !gotMsg.ArrivalDate.Equal(wantMsg.ArrivalDate) {
+		t.Errorf("got arrival date %s, want %s", gotMsg.ArrivalDate, wantMsg.ArrivalDate)
+	}
+	gotMsg.ArrivalDate = wantMsg.ArrivalDate
+	if !reflect.DeepEqual(gotMsg, wantMsg) {
+		t.Errorf("got %+v, want %+v", gotMsg, wantMsg)
+	}
+
+	recips := ds.PerRecipient()
+	if len(recips) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(recips))
+	}
+
+	r := recips[0]
+	if r.OriginalRecipient != (TypedValue{Type: "rfc822", Value: "Someone@example.org"}) {
+		t.Errorf("got original recipient %v", r.OriginalRecipient)
+	}
+	if r.FinalRecipient != (TypedValue{Type: "rfc822", Value: "someone@example.org"}) {
+		t.Errorf("got final recipient %v", r.FinalRecipient)
+	}
+	if r.Action != "failed" {
+		t.Errorf("got action %s, want failed", r.Action)
+	}
+	if r.Status != (StatusCode{5, 1, 1}) || !r.Status.IsPermanent() {
+		t.Errorf("got status %s, want 5.1.1", r.Status)
+	}
+	if r.RemoteMTA.String() != "dns; mail.example.org" {
+		t.Errorf("got remote MTA %s", r.RemoteMTA)
+	}
+	if want := (TypedValue{Type: "smtp", Value: "550 5.1.1 <someone@example.org>: Recipient address rejected"}); r.DiagnosticCode != want {
+		t.Errorf("got diagnostic code %v, want %v", r.DiagnosticCode, want)
+	}
+	if want := time.Date(2006, 1, 2, 15, 5, 5, 0, loc); !r.LastAttemptDate.Equal(want) {
+		t.Errorf("got last attempt date %s, want %s", r.LastAttemptDate, want)
+	}
+
+	r = recips[1]
+	if r.Action != "delayed" || r.Status.String() != "4.4.7" || !r.Status.IsTransient() {
+		t.Errorf("got action %s and status %s", r.Action, r.Status)
+	}
+	if want := time.Date(2006, 1, 3, 15, 4, 5, 0, loc); !r.WillRetryUntil.Equal(want) {
+		t.Errorf("got will-retry-until %s, want %s", r.WillRetryUntil, want)
+	}
+}
+
+func TestParseStatusCode(t *testing.T) {
+	cases := []struct {
+		inp     string
+		want    StatusCode
+		wantErr bool
+	}{
+		{inp: "2.0.0", want: StatusCode{2, 0, 0}},
+		{inp: " 5.7.1 (delivery not authorized)", want: StatusCode{5, 7, 1}},
+		{inp: "4.999.999", want: StatusCode{4, 999, 999}},
+		{inp: "3.1.1", wantErr: true},
+		{inp: "5.1", wantErr: true},
+		{inp: "5.1.x", wantErr: true},
+		{inp: "", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ParseStatusCode(tc.inp)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: got no error", tc.inp)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tc.inp, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.inp, got, tc.want)
+		}
+	}
+}
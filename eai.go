@@ -0,0 +1,255 @@
+package rmime
+
+import (
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bobg/errors"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidAddress is the error indicating an address that fails validation.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrNonASCIILocalPart is the error produced when converting to ASCII
+// an address whose local part contains non-ASCII characters.
+// Such an address can be transmitted only with SMTPUTF8 (RFC6531).
+var ErrNonASCIILocalPart = errors.New("non-ASCII local part")
+
+// idnaProfile is the IDNA2008 profile (RFC5891) used for converting
+// and validating domains.
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+)
+
+// Limits from RFC5321 section 4.5.3.1.
+const (
+	maxLocalPartLen = 64
+	maxAddressLen   = 254
+)
+
+// LocalPart returns the part of a's address preceding the last @,
+// unquoted.
+// If there is no @,
+// it is the whole address.
+func (a *Address) LocalPart() string {
+	if i := strings.LastIndexByte(a.Address, '@'); i >= 0 {
+		return a.Address[:i]
+	}
+	return a.Address
+}
+
+// Domain returns the part of a's address following the last @,
+// which may be a domain literal such as "[192.0.2.1]".
+// If there is no @,
+// it is empty.
+func (a *Address) Domain() string {
+	if i := strings.LastIndexByte(a.Address, '@'); i >= 0 {
+		return a.Address[i+1:]
+	}
+	return ""
+}
+
+// ASCII returns a's address with its domain converted to A-labels (punycode),
+// e.g. "user@xn--bcher-kva.example" for "user@bücher.example".
+// It is an error if the local part contains non-ASCII characters;
+// see ErrNonASCIILocalPart.
+// An address with no domain is returned unchanged.
+func (a *Address) ASCII() (string, error) {
+	local := a.LocalPart()
+	if !isASCII(local) {
+		return "", errors.Wrapf(ErrNonASCIILocalPart, "in %s", a.Address)
+	}
+	if a.Domain() == "" {
+		return a.Address, nil
+	}
+	domain, err := asciiDomain(a.Domain())
+	if err != nil {
+		return "", errors.Wrapf(err, "converting domain of %s", a.Address)
+	}
+	return local + "@" + domain, nil
+}
+
+// Unicode returns a's address with its domain converted to U-labels,
+// e.g. "user@bücher.example" for "user@xn--bcher-kva.example".
+// An address with no domain is returned unchanged.
+func (a *Address) Unicode() (string, error) {
+	domain := a.Domain()
+	if domain == "" {
+		return a.Address, nil
+	}
+	if !isDomainLiteral(domain) {
+		var err error
+		domain, err = idnaProfile.ToUnicode(domain)
+		if err != nil {
+			return "", errors.Wrapf(err, "converting domain of %s", a.Address)
+		}
+	}
+	return a.LocalPart() + "@" + domain, nil
+}
+
+// RequiresSMTPUTF8 tells whether a's address can be transmitted
+// only with the SMTPUTF8 extension (RFC6531):
+// whether it contains non-ASCII characters
+// that conversion to A-labels cannot remove.
+func (a *Address) RequiresSMTPUTF8() bool {
+	_, err := a.ASCII()
+	return err != nil && !isASCII(a.Address)
+}
+
+// Validate checks a's address against the syntax of RFC5321 and RFC5322,
+// as extended for internationalized email (RFC6531 section 3.3 and RFC6532 section 3.2):
+//   - The local part must be a dot-atom
+//     or the contents of a quoted string,
+//     in which non-ASCII characters are allowed.
+//     It must be valid UTF-8 in Unicode Normalization Form C (RFC6532 section 3.1)
+//     and no longer than 64 octets.
+//   - The domain must be a valid IDNA2008 domain name (RFC5891)
+//     in U-labels or A-labels,
+//     or an address literal.
+//   - The address, with its domain in A-labels,
+//     must be no longer than 254 octets.
+//
+// Errors wrap ErrInvalidAddress.
+func (a *Address) Validate() error {
+	i := strings.LastIndexByte(a.Address, '@')
+	if i < 0 {
+		return errors.Wrapf(ErrInvalidAddress, "no @ in %q", a.Address)
+	}
+	local, domain := a.Address[:i], a.Address[i+1:]
+	if err := validateLocalPart(local); err != nil {
+		return errors.Wrapf(ErrInvalidAddress, "local part of %q: %s", a.Address, err)
+	}
+	if domain == "" {
+		return errors.Wrapf(ErrInvalidAddress, "empty domain in %q", a.Address)
+	}
+	if isDomainLiteral(domain) {
+		if !validDomainLiteral(domain) {
+			return errors.Wrapf(ErrInvalidAddress, "bad address literal in %q", a.Address)
+		}
+		return nil
+	}
+	ascii, err := idnaProfile.ToASCII(domain)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidAddress, "domain of %q: %s", a.Address, err)
+	}
+	if n := len(local) + 1 + len(ascii); n > maxAddressLen {
+		return errors.Wrapf(ErrInvalidAddress, "%q is %d octets long", a.Address, n)
+	}
+	return nil
+}
+
+func validateLocalPart(local string) error {
+	switch {
+	case local == "":
+		return errors.New("empty")
+	case len(local) > maxLocalPartLen:
+		return errors.Errorf("%d octets long", len(local))
+	case !utf8.ValidString(local):
+		return errors.New("invalid UTF-8")
+	case !norm.NFC.IsNormalString(local):
+		return errors.New("not in Normalization Form C")
+	}
+	if isDotAtom(local) {
+		return nil
+	}
+	// Otherwise it must be quoted,
+	// which permits anything but control characters.
+	for _, r := range local {
+		if r < ' ' || r == 0x7f || (0x80 <= r && r < 0xa0) {
+			return errors.Errorf("control character %U", r)
+		}
+	}
+	return nil
+}
+
+// isDotAtom tells whether s is a dot-atom,
+// allowing non-ASCII characters in atoms (RFC6532 section 3.2).
+func isDotAtom(s string) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for i := 0; i < len(atom); i++ {
+			if c := atom[i]; c < 0x80 && !isAtext(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDomainLiteral(domain string) bool {
+	return strings.HasPrefix(domain, "[")
+}
+
+// validDomainLiteral tells whether s is an RFC5321 address literal
+// (section 4.1.3):
+// an IPv4 address,
+// an IPv6 address with the prefix "IPv6:",
+// or a general address literal of the form "tag:content".
+func validDomainLiteral(s string) bool {
+	if !strings.HasSuffix(s, "]") {
+		return false
+	}
+	s = s[1 : len(s)-1]
+	if tag, content, ok := strings.Cut(s, ":"); ok {
+		if strings.EqualFold(tag, "IPv6") {
+			addr, err := netip.ParseAddr(content)
+			return err == nil && addr.Is6()
+		}
+		if tag == "" || content == "" {
+			return false
+		}
+		for i := 0; i < len(content); i++ {
+			if c := content[i]; c < 33 || c > 126 || c == '[' || c == ']' || c == '\\' {
+				return false
+			}
+		}
+		return true
+	}
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is4()
+}
+
+// Equal tells whether a and b have the same address
+// (ignoring display names).
+// Local parts are compared exactly,
+// after Unicode normalization,
+// since their interpretation is up to the receiving host
+// (RFC5321 section 2.4).
+// Domains are compared case-insensitively,
+// with U-labels and their equivalent A-labels considered equal.
+func (a *Address) Equal(b *Address) bool {
+	if norm.NFC.String(a.LocalPart()) != norm.NFC.String(b.LocalPart()) {
+		return false
+	}
+	da, errA := asciiDomain(a.Domain())
+	db, errB := asciiDomain(b.Domain())
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a.Domain(), b.Domain())
+	}
+	return strings.EqualFold(da, db)
+}
+
+// asciiDomain converts domain to A-labels,
+// unless it is a domain literal.
+func asciiDomain(domain string) (string, error) {
+	if isDomainLiteral(domain) {
+		return domain, nil
+	}
+	return idnaProfile.ToASCII(domain)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package rmime
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bobg/errors"
+)
+
+func TestAddressParts(t *testing.T) {
+	cases := []struct {
+		addr, local, domain string
+	}{
+		{addr: "user@example.com", local: "user", domain: "example.com"},
+		{addr: "john doe@example.com", local: "john doe", domain: "example.com"},
+		{addr: "a@b@example.com", local: "a@b", domain: "example.com"},
+		{addr: "a@[192.0.2.1]", local: "a", domain: "[192.0.2.1]"},
+		{addr: "postmaster", local: "postmaster", domain: ""},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			a := &Address{Address: tc.addr}
+			if got := a.LocalPart(); got != tc.local {
+				t.Errorf("got local part %q, want %q", got, tc.local)
+			}
+			if got := a.Domain(); got != tc.domain {
+				t.Errorf("got domain %q, want %q", got, tc.domain)
+			}
+		})
+	}
+}
+
+func TestAddressIDNA(t *testing.T) {
+	cases := []struct {
+		addr         string
+		ascii        string
+		wantASCIIErr error
+		unicode      string
+		smtputf8     bool
+	}{{
+		addr:    "user@bücher.example",
+		ascii:   "user@xn--bcher-kva.example",
+		unicode: "user@bücher.example",
+	}, {
+		addr:    "user@xn--bcher-kva.example",
+		ascii:   "user@xn--bcher-kva.example",
+		unicode: "user@bücher.example",
+	}, {
+		addr:    "User@Example.COM",
+		ascii:   "User@example.com",
+		unicode: "User@example.com",
+	}, {
+		addr:         "用户@例子.广告",
+		wantASCIIErr: ErrNonASCIILocalPart,
+		unicode:      "用户@例子.广告",
+		smtputf8:     true,
+	}, {
+		addr:    "a@[IPv6:2001:db8::1]",
+		ascii:   "a@[IPv6:2001:db8::1]",
+		unicode: "a@[IPv6:2001:db8::1]",
+	}, {
+		addr:    "postmaster",
+		ascii:   "postmaster",
+		unicode: "postmaster",
+	}, {
+		addr:    "local@",
+		ascii:   "local@",
+		unicode: "local@",
+	}, {
+		addr:         "zoë",
+		wantASCIIErr: ErrNonASCIILocalPart,
+		unicode:      "zoë",
+		smtputf8:     true,
+	}}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			a := &Address{Address: tc.addr}
+			got, err := a.ASCII()
+			if tc.wantASCIIErr != nil {
+				if !errors.Is(err, tc.wantASCIIErr) {
+					t.Errorf("got error %v, want %v", err, tc.wantASCIIErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if got != tc.ascii {
+				t.Errorf("got ASCII %q, want %q", got, tc.ascii)
+			}
+			got, err = a.Unicode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.unicode {
+				t.Errorf("got Unicode %q, want %q", got, tc.unicode)
+			}
+			if got := a.RequiresSMTPUTF8(); got != tc.smtputf8 {
+				t.Errorf("got RequiresSMTPUTF8 %v, want %v", got, tc.smtputf8)
+			}
+		})
+	}
+}
+
+func TestAddressValidate(t *testing.T) {
+	cases := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: "user@example.com"},
+		{addr: "first.last+tag@example.com"},
+		{addr: "john doe@example.com"},
+		{addr: "θσερ@εχαμπλε.ψομ"},
+		{addr: "用户@例子.广告"},
+		{addr: "user@xn--bcher-kva.example"},
+		{addr: "a@[192.0.2.1]"},
+		{addr: "a@[IPv6:2001:db8::1]"},
+		{addr: "no-at-sign", wantErr: true},
+		{addr: "@example.com", wantErr: true},
+		{addr: "user@", wantErr: true},
+		{addr: "user@exa mple.com", wantErr: true},
+		{addr: "user@-example.com", wantErr: true},
+		{addr: "a\x01b@example.com", wantErr: true},
+		{addr: "cafe\u0301@example.com", wantErr: true}, // not NFC
+		{addr: "bad\xffutf8@example.com", wantErr: true},
+		{addr: strings.Repeat("x", 65) + "@example.com", wantErr: true},
+		{addr: "a@[300.1.1.1]", wantErr: true},
+		{addr: "a@[IPv6:192.0.2.1]", wantErr: true},
+		{addr: "a@" + strings.Repeat("x.", 130) + "com", wantErr: true},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			err := (&Address{Address: tc.addr}).Validate()
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidAddress) {
+					t.Errorf("got %v, want ErrInvalidAddress", err)
+				}
+			} else if err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+		})
+	}
+}
+
+func TestAddressEqual(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "user@example.com", b: "user@EXAMPLE.com", want: true},
+		{a: "user@example.com", b: "User@example.com", want: false},
+		{a: "user@bücher.example", b: "user@XN--BCHER-KVA.example", want: true},
+		{a: "user@Bücher.example", b: "user@bücher.example", want: true},
+		{a: "café@example.com", b: "cafe\u0301@example.com", want: true},
+		{a: "a@[192.0.2.1]", b: "a@[192.0.2.1]", want: true},
+		{a: "a@example.com", b: "a@example.org", want: false},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			a, b := &Address{Address: tc.a}, &Address{Address: tc.b, Name: "ignored"}
+			if got := a.Equal(b); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
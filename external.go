@@ -0,0 +1,192 @@
+package rmime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bobg/errors"
+)
+
+// ExternalBody is the type of a parsed message/external-body body part
+// (RFC2046 section 5.2.3).
+// Such a part refers to content stored elsewhere.
+//
+// Most fields are parsed from the parameters of the part's Content-Type field,
+// and are for reading only:
+// changing them does not change the part's header.
+// Header and Body are the "phantom" header and body that make up the part's own body.
+// The phantom header describes the external content
+// (its Content-Type, Content-Transfer-Encoding, Content-ID, and so on);
+// the phantom body is normally empty,
+// except with access-type mail-server,
+// where it holds the commands to send to the server.
+type ExternalBody struct {
+	AccessType string    `json:"access_type"`          // Canonicalized to lowercase.
+	Name       string    `json:"name,omitempty"`       // For ftp, tftp, anon-ftp, and local-file.
+	Site       string    `json:"site,omitempty"`       // For ftp, tftp, anon-ftp, and local-file.
+	Directory  string    `json:"directory,omitempty"`  // For ftp and anon-ftp.
+	Mode       string    `json:"mode,omitempty"`       // For ftp, tftp, and anon-ftp.
+	Server     string    `json:"server,omitempty"`     // For mail-server.
+	Subject    string    `json:"subject,omitempty"`    // For mail-server.
+	URL        string    `json:"url,omitempty"`        // For URL (RFC2017).
+	Expiration time.Time `json:"expiration,omitempty"` // Zero if absent or unparseable.
+	Size       int64     `json:"size,omitempty"`       // -1 if absent or unparseable.
+	Permission string    `json:"permission,omitempty"` // "read" or "read-write".
+
+	Header *Header `json:"header"`
+	Body   string  `json:"body"`
+}
+
+var errNoAccessType = errors.New("no access-type parameter in message/external-body Content-Type field")
+
+func (ps *parser) readExternalBody(r Reader, header *Header) (*ExternalBody, error) {
+	params := header.Params()
+	accessType := strings.ToLower(strings.TrimSpace(params["access-type"]))
+	if accessType == "" {
+		return nil, errNoAccessType
+	}
+
+	eb := &ExternalBody{
+		AccessType: accessType,
+		Name:       params["name"],
+		Site:       params["site"],
+		Directory:  params["directory"],
+		Mode:       params["mode"],
+		Server:     params["server"],
+		Subject:    params["subject"],
+		Permission: strings.ToLower(strings.TrimSpace(params["permission"])),
+		Size:       -1,
+	}
+
+	// RFC2017 allows a long URL to be broken up with whitespace,
+	// which is not part of it.
+	eb.URL = strings.Join(strings.Fields(params["url"]), "")
+
+	if v, ok := params["expiration"]; ok {
+		eb.Expiration = parseDate(v)
+	}
+	if v, ok := params["size"]; ok {
+		if size, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			eb.Size = size
+		}
+	}
+
+	h, r, err := ps.readHeader(r, "text/plain")
+	if errors.Is(err, io.EOF) && ps.opts.Lenient {
+		ps.warn(WarnTruncatedHeader, "empty message/external-body")
+		h, err = &Header{DefaultType: "text/plain"}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading phantom header")
+	}
+	eb.Header = h
+
+	eb.Body, err = readString(ps.bodyReader(r))
+	return eb, err
+}
+
+// ExternalBodyResolver fetches the content to which a message/external-body part refers.
+// The content is returned as is,
+// still encoded according to the Content-Transfer-Encoding in the phantom header
+// (see DecodeBody).
+type ExternalBodyResolver interface {
+	Resolve(context.Context, *ExternalBody) (io.ReadCloser, error)
+}
+
+// ResolverMap is an ExternalBodyResolver that dispatches on the access-type of its input.
+// Its keys must be lowercase.
+type ResolverMap map[string]ExternalBodyResolver
+
+// Resolve implements ExternalBodyResolver.
+func (m ResolverMap) Resolve(ctx context.Context, eb *ExternalBody) (io.ReadCloser, error) {
+	res, ok := m[eb.AccessType]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnimplemented, "access-type %s", eb.AccessType)
+	}
+	return res.Resolve(ctx, eb)
+}
+
+// ErrExpired is the error indicating that a message/external-body part refers to expired content.
+var ErrExpired = errors.New("external body has expired")
+
+// LocalFileResolver is an ExternalBodyResolver for access-type local-file.
+type LocalFileResolver struct {
+	// Root is the directory in which to resolve file names.
+	// It is required,
+	// since the names come from messages that may not be trusted.
+	// Names must be relative,
+	// and may not refer outside of Root,
+	// even by way of symbolic links.
+	Root string
+
+	// Sites, if not empty,
+	// is the list of site names (RFC2046: "a domain specification for a machine or set of machines")
+	// at which this resolver's files are accessible.
+	// If an ExternalBody specifies a site not in this list,
+	// it cannot be resolved.
+	Sites []string
+
+	// Now, if not nil,
+	// is used in place of time.Now to check expiration times.
+	Now func() time.Time
+}
+
+// Resolve implements ExternalBodyResolver.
+func (res LocalFileResolver) Resolve(ctx context.Context, eb *ExternalBody) (io.ReadCloser, error) {
+	if eb.AccessType != "local-file" {
+		return nil, fmt.Errorf("access-type %s is not local-file", eb.AccessType)
+	}
+	if eb.Name == "" {
+		return nil, fmt.Errorf("no name parameter")
+	}
+	if res.Root == "" {
+		return nil, fmt.Errorf("no root directory")
+	}
+	if eb.Site != "" && len(res.Sites) > 0 && !containsFold(res.Sites, eb.Site) {
+		return nil, fmt.Errorf("site %s is not local", eb.Site)
+	}
+	if !eb.Expiration.IsZero() {
+		now := time.Now
+		if res.Now != nil {
+			now = res.Now
+		}
+		if now().After(eb.Expiration) {
+			return nil, ErrExpired
+		}
+	}
+
+	name := filepath.FromSlash(eb.Name)
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("name %s is not within %s", eb.Name, res.Root)
+	}
+
+	// Resolve symbolic links in both paths
+	// to be sure that the file really is within Root.
+	root, err := filepath.EvalSymlinks(res.Root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving %s", res.Root)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving %s", eb.Name)
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("name %s is not within %s", eb.Name, res.Root)
+	}
+	return os.Open(path)
+}
+
+func containsFold(strs []string, s string) bool {
+	for _, str := range strs {
+		if strings.EqualFold(str, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package rmime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobg/errors"
+)
+
+func TestExternalBody(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("aGVsbG8K"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inp := `From: foo
+Content-Type: multipart/mixed; boundary=b
+
+--b
+Content-Type: message/external-body; access-type=local-file;
+ name="data.txt"; site="host.example";
+ expiration="Thu, 1 Jan 2099 00:00:00 +0000"; size=8
+
+Content-Type: text/plain
+Content-Transfer-Encoding: base64
+Content-Id: <data@example>
+
+--b
+Content-Type: message/external-body; access-type=URL;
+ URL="http://example.com/
+      long/path"
+
+Content-Type: image/png
+
+--b--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != inp {
+		t.Errorf("message re-rendering mismatch, got:\n%s\n\nwant:\n%s", got, inp)
+	}
+
+	parts := m.B.(*Multipart).Parts
+	eb, ok := parts[0].B.(*ExternalBody)
+	if !ok {
+		t.Fatalf("got body of type %T, want *ExternalBody", parts[0].B)
+	}
+	if eb.AccessType != "local-file" || eb.Name != "data.txt" || eb.Site != "host.example" || eb.Size != 8 {
+		t.Errorf("got %+v", eb)
+	}
+	if want := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC); !eb.Expiration.Equal(want) {
+		t.Errorf("got expiration %s, want %s", eb.Expiration, want)
+	}
+	if got := eb.Header.Type(); got != "text/plain" {
+		t.Errorf("got phantom type %s, want text/plain", got)
+	}
+
+	eb2 := parts[1].B.(*ExternalBody)
+	if eb2.URL != "http://example.com/long/path" {
+		t.Errorf("got URL %s", eb2.URL)
+	}
+
+	var res ExternalBodyResolver = ResolverMap{
+		"local-file": LocalFileResolver{Root: dir, Sites: []string{"HOST.example"}},
+	}
+	rc, err := res.Resolve(context.Background(), eb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	dec, err := DecodeBody(rc, eb.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello\n" {
+		t.Errorf("got resolved content %q, want %q", got, "hello\n")
+	}
+
+	if _, err := res.Resolve(context.Background(), eb2); !errors.Is(err, ErrUnimplemented) {
+		t.Errorf("got error %v resolving URL, want %v", err, ErrUnimplemented)
+	}
+
+	expired := LocalFileResolver{Root: dir, Now: func() time.Time { return time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC) }}
+	if _, err := expired.Resolve(context.Background(), eb); !errors.Is(err, ErrExpired) {
+		t.Errorf("got error %v, want %v", err, ErrExpired)
+	}
+
+	escape := *eb
+	escape.Name = "../data.txt"
+	if _, err := (LocalFileResolver{Root: dir}).Resolve(context.Background(), &escape); err == nil {
+		t.Error("got no error resolving a name outside the root")
+	}
+}
+
+func TestLocalFileResolverConfinement(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink("data.txt", filepath.Join(root, "inner.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		root, name string
+		wantErr    bool
+	}{
+		{root: root, name: "data.txt"},
+		{root: root, name: "link.txt", wantErr: true},
+		{root: root, name: "inner.txt"},
+		{root: root, name: "../secret.txt", wantErr: true},
+		{root: root, name: filepath.Join(dir, "secret.txt"), wantErr: true},
+		{root: "", name: filepath.Join(root, "data.txt"), wantErr: true},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			eb := &ExternalBody{AccessType: "local-file", Name: tc.name}
+			rc, err := (LocalFileResolver{Root: tc.root}).Resolve(context.Background(), eb)
+			if tc.wantErr {
+				if err == nil {
+					rc.Close()
+					t.Error("got no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "data" {
+				t.Errorf("got %q, want %q", got, "data")
+			}
+		})
+	}
+}
+
+func TestExternalBodyLenientTruncated(t *testing.T) {
+	cases := []string{
+		"Content-Type: message/external-body; access-type=local-file; name=x\n\n",
+		"Content-Type: message/external-body; access-type=local-file; name=x\n\nContent-Type: text/plain",
+	}
+	for i, inp := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			m, warnings, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{Lenient: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			eb, ok := m.B.(*ExternalBody)
+			if !ok {
+				t.Fatalf("got body of type %T, want *ExternalBody", m.B)
+			}
+			if eb.Name != "x" {
+				t.Errorf("got name %q, want x", eb.Name)
+			}
+			if len(warnings) != 1 || warnings[0].Kind != WarnTruncatedHeader {
+				t.Errorf("got warnings %v, want one %v", warnings, WarnTruncatedHeader)
+			}
+		})
+	}
+}
@@ -6,11 +6,56 @@ import "strings"
 type Field struct {
 	N string   `json:"name"`  // Name of the field.
 	V []string `json:"value"` // Values of the field.
+
+	span Span   // Location in the input, if recorded (see ParseOptions.RecordOffsets).
+	raw  string // Original bytes, if recorded (see ParseOptions.PreserveRaw).
 }
 
-// Name returns the name of a field in canonical form.
+// Name returns the name of a field in canonical form (see CanonicalName).
 func (f Field) Name() string {
-	return strings.Title(strings.ToLower(strings.TrimSpace(f.N)))
+	return CanonicalName(f.N)
+}
+
+// CanonicalName returns the canonical form of the field name name.
+// For well-known fields this is the form in which they are registered
+// (e.g. "Message-ID", "MIME-Version", "DKIM-Signature").
+// Otherwise each hyphen-separated word is capitalized
+// and the rest made lowercase
+// (e.g. "X-Mailer").
+func CanonicalName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if c, ok := canonicalNames[name]; ok {
+		return c
+	}
+	words := strings.Split(name, "-")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, "-")
+}
+
+// canonicalNames maps the lowercase names of well-known fields
+// whose canonical forms are not simply capitalized
+// to those forms.
+var canonicalNames = map[string]string{
+	"arc-authentication-results": "ARC-Authentication-Results",
+	"arc-message-signature":      "ARC-Message-Signature",
+	"arc-seal":                   "ARC-Seal",
+	"content-id":                 "Content-ID",
+	"content-md5":                "Content-MD5",
+	"dkim-signature":             "DKIM-Signature",
+	"domainkey-signature":        "DomainKey-Signature",
+	"message-id":                 "Message-ID",
+	"mime-version":               "MIME-Version",
+	"mt-priority":                "MT-Priority",
+	"nntp-posting-host":          "NNTP-Posting-Host",
+	"received-spf":               "Received-SPF",
+	"resent-message-id":          "Resent-Message-ID",
+	"tls-report-domain":          "TLS-Report-Domain",
+	"tls-report-submitter":       "TLS-Report-Submitter",
+	"tls-required":               "TLS-Required",
 }
 
 // Value returns the values of the field combined with canonical
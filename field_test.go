@@ -1,6 +1,7 @@
 package rmime
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 )
@@ -33,6 +34,11 @@ func TestFieldName(t *testing.T) {
 		{inp: "Foo-bAR", want: "Foo-Bar"},
 		{inp: "FOO-bAR", want: "Foo-Bar"},
 		{inp: "fOO-bAR", want: "Foo-Bar"},
+
+		{inp: "message-id", want: "Message-ID"},
+		{inp: "MIME-VERSION", want: "MIME-Version"},
+		{inp: " dkim-signature ", want: "DKIM-Signature"},
+		{inp: "x--y", want: "X--Y"},
 	}
 
 	for i, tc := range cases {
@@ -45,3 +51,33 @@ func TestFieldName(t *testing.T) {
 		})
 	}
 }
+
+func TestFieldWriteTo(t *testing.T) {
+	cases := []struct {
+		f    Field
+		want string
+	}{
+		{f: Field{N: "Subject", V: []string{" hello"}}, want: "Subject: hello\n"},
+		{f: Field{N: "Subject", V: []string{" hello", " world"}}, want: "Subject: hello\n world\n"},
+		{f: Field{N: "Subject", V: []string{" hello", "\tworld"}}, want: "Subject: hello\n\tworld\n"},
+		{f: Field{N: "Subject", V: []string{" hello", "world"}}, want: "Subject: hello\n world\n"},
+		{f: Field{N: "Subject", V: []string{""}}, want: "Subject:\n"},
+		{f: Field{N: "Subject"}, want: "Subject:\n"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			n, err := tc.f.WriteTo(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("got count %d, want %d", n, buf.Len())
+			}
+		})
+	}
+}
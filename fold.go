@@ -0,0 +1,402 @@
+package rmime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NewField produces a header field with the given name and value,
+// ready for writing.
+// The value is plain text,
+// which may contain non-ASCII characters.
+// NewField encodes them as RFC2047 encoded-words where needed,
+// choosing the Q or B encoding according to which is shorter,
+// and folds the value at whitespace
+// into lines of at most 78 characters where possible
+// (RFC5322 section 2.2.3).
+//
+// How the value is encoded depends on the field:
+//   - In address fields (From, To, Cc, and so on),
+//     the value is parsed as an address list,
+//     and display names are encoded as phrases.
+//     If it cannot be parsed,
+//     it is left unencoded,
+//     since encoded-words are not permitted everywhere in an address.
+//     See also NewAddressField.
+//   - In Keywords, each comma-separated keyword is encoded as a phrase.
+//   - In the other structured fields known to this package
+//     (Content-Type, Date, Message-ID, Received, and so on),
+//     only text in comments is encoded.
+//   - The value of any other field,
+//     including Subject and unknown fields,
+//     is unstructured text.
+func NewField(name, value string) *Field {
+	key := strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case addressFields[key]:
+		if list, err := ParseAddressList(value); err == nil {
+			return NewAddressListField(name, list)
+		}
+		return &Field{N: name, V: fold(name, tokenize(value, true))}
+
+	case key == "keywords":
+		var phrases []string
+		for _, kw := range strings.Split(value, ",") {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				phrases = append(phrases, encodePhrase(kw))
+			}
+		}
+		return &Field{N: name, V: fold(name, tokenize(strings.Join(phrases, ", "), true))}
+
+	case structuredFields[key]:
+		return &Field{N: name, V: fold(name, tokenize(encodeComments(value), true))}
+	}
+	return &Field{N: name, V: fold(name, encodeUnstructured(value))}
+}
+
+// NewAddressField produces a header field with the given name
+// whose value is the given list of addresses,
+// with display names encoded and the value folded as in NewField.
+func NewAddressField(name string, addrs []*Address) *Field {
+	list := make(AddressList, 0, len(addrs))
+	for _, a := range addrs {
+		list = append(list, a)
+	}
+	return NewAddressListField(name, list)
+}
+
+// NewAddressListField is like NewAddressField
+// but takes an AddressList,
+// which may include groups.
+func NewAddressListField(name string, list AddressList) *Field {
+	return &Field{N: name, V: fold(name, tokenize(list.String(), true))}
+}
+
+var addressFields = map[string]bool{
+	"from":                        true,
+	"sender":                      true,
+	"reply-to":                    true,
+	"to":                          true,
+	"cc":                          true,
+	"bcc":                         true,
+	"resent-from":                 true,
+	"resent-sender":               true,
+	"resent-to":                   true,
+	"resent-cc":                   true,
+	"resent-bcc":                  true,
+	"disposition-notification-to": true,
+}
+
+var structuredFields = map[string]bool{
+	"authentication-results":    true,
+	"content-disposition":       true,
+	"content-id":                true,
+	"content-language":          true,
+	"content-location":          true,
+	"content-md5":               true,
+	"content-transfer-encoding": true,
+	"content-type":              true,
+	"date":                      true,
+	"dkim-signature":            true,
+	"in-reply-to":               true,
+	"message-id":                true,
+	"mime-version":              true,
+	"received":                  true,
+	"received-spf":              true,
+	"references":                true,
+	"resent-date":               true,
+	"resent-message-id":         true,
+	"return-path":               true,
+}
+
+// encodePhrase renders s as an RFC5322 phrase:
+// as is if it consists of atoms,
+// otherwise as a quoted string,
+// or as encoded-words if it contains non-ASCII text.
+func encodePhrase(s string) string {
+	if needsEncoding(s) {
+		return encodeWords(s)
+	}
+	if !strings.Contains(s, "=?") {
+		isAtoms := true
+		for _, word := range strings.Fields(s) {
+			for i := 0; i < len(word); i++ {
+				if !isAtext(word[i]) {
+					isAtoms = false
+				}
+			}
+		}
+		if isAtoms && len(strings.Fields(s)) > 0 {
+			return strings.Join(strings.Fields(s), " ")
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func isAtext(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// needsEncoding tells whether s must be encoded as encoded-words:
+// whether it contains non-ASCII or control characters,
+// or looks like an encoded-word itself.
+func needsEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 0x7f || (c < ' ' && c != '\t') {
+			return true
+		}
+	}
+	return strings.HasPrefix(s, "=?") && strings.HasSuffix(s, "?=")
+}
+
+// encodeComments encodes the contents of any comments in the structured field value s
+// that need it.
+func encodeComments(s string) string {
+	var (
+		buf      strings.Builder
+		comment  strings.Builder
+		depth    int
+		inQuotes bool
+		escaped  bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if depth > 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+			}
+			if depth > 0 {
+				comment.WriteByte(c)
+				continue
+			}
+			text := comment.String()
+			if needsEncoding(text) {
+				text = encodeWords(text)
+			}
+			buf.WriteString("(" + text + ")")
+			comment.Reset()
+			continue
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '(' && !inQuotes:
+			depth++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	if depth > 0 {
+		// Unterminated comment.
+		buf.WriteString("(" + comment.String())
+	}
+	return buf.String()
+}
+
+// foldToken is a unit of a header field value
+// that may be preceded by a line break,
+// if sep is not empty.
+type foldToken struct {
+	sep, text string
+}
+
+// tokenize splits s into tokens at whitespace.
+// If quotes is true,
+// it does not split quoted strings.
+// Line breaks in s are turned into spaces.
+func tokenize(s string, quotes bool) []foldToken {
+	var (
+		toks          []foldToken
+		sep, cur      strings.Builder
+		inQuotes, esc bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case esc:
+			esc = false
+		case quotes && inQuotes && c == '\\':
+			esc = true
+		case quotes && c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			if cur.Len() > 0 {
+				toks = append(toks, foldToken{sep: sep.String(), text: cur.String()})
+				sep.Reset()
+				cur.Reset()
+			}
+			if c == '\r' || c == '\n' {
+				c = ' '
+			}
+			sep.WriteByte(c)
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	if cur.Len() > 0 {
+		toks = append(toks, foldToken{sep: sep.String(), text: cur.String()})
+	}
+	return toks
+}
+
+// encodeUnstructured tokenizes the unstructured field value s,
+// replacing each run of words that need encoding
+// (with the whitespace between them)
+// with encoded-words.
+func encodeUnstructured(s string) []foldToken {
+	var (
+		toks   []foldToken
+		run    []foldToken
+		result []foldToken
+	)
+	toks = tokenize(s, false)
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		var text strings.Builder
+		for i, t := range run {
+			if i > 0 {
+				text.WriteString(t.sep)
+			}
+			text.WriteString(t.text)
+		}
+		for i, word := range strings.Split(encodeWords(text.String()), " ") {
+			sep := " "
+			if i == 0 {
+				sep = run[0].sep
+			}
+			result = append(result, foldToken{sep: sep, text: word})
+		}
+		run = nil
+	}
+	for _, t := range toks {
+		if needsEncoding(t.text) {
+			run = append(run, t)
+			continue
+		}
+		flush()
+		result = append(result, t)
+	}
+	flush()
+	return result
+}
+
+const foldWidth = 78
+
+// fold arranges toks into the lines of the value of a field with the given name,
+// breaking lines before tokens that would otherwise extend past foldWidth.
+// The result is suitable for Field.V.
+func fold(name string, toks []foldToken) []string {
+	var (
+		lines []string
+		line  strings.Builder
+		col   = len(name) + 1
+	)
+	for i, t := range toks {
+		sep := t.sep
+		if i == 0 {
+			sep = " "
+		} else if sep != "" && line.Len() > 0 && col+len(sep)+len(t.text) > foldWidth {
+			lines = append(lines, line.String())
+			line.Reset()
+			col = 0
+		}
+		line.WriteString(sep)
+		line.WriteString(t.text)
+		col += len(sep) + len(t.text)
+	}
+	return append(lines, line.String())
+}
+
+// The longest permitted encoded-word (RFC2047 section 2),
+// less the length of "=?utf-8?q??=".
+const maxEncodedText = 75 - 12
+
+// encodeWords encodes s as a sequence of RFC2047 encoded-words in UTF-8,
+// separated by spaces.
+// It uses the Q or B encoding,
+// whichever is shorter.
+// The Q encoding is the restricted form permitted in phrases (RFC2047 section 5(3)),
+// so the result may be used wherever encoded-words are.
+func encodeWords(s string) string {
+	qlen := 0
+	for i := 0; i < len(s); i++ {
+		qlen += qLen(s[i])
+	}
+	useQ := qlen <= base64.StdEncoding.EncodedLen(len(s))
+
+	var words []string
+	for len(s) > 0 {
+		var n, size int
+		for n < len(s) {
+			_, runeLen := utf8.DecodeRuneInString(s[n:])
+			if useQ {
+				add := 0
+				for j := n; j < n+runeLen; j++ {
+					add += qLen(s[j])
+				}
+				if size+add > maxEncodedText && n > 0 {
+					break
+				}
+				size += add
+			} else if base64.StdEncoding.EncodedLen(n+runeLen) > maxEncodedText && n > 0 {
+				break
+			}
+			n += runeLen
+		}
+		chunk := s[:n]
+		s = s[n:]
+		if useQ {
+			var buf strings.Builder
+			for i := 0; i < len(chunk); i++ {
+				switch c := chunk[i]; {
+				case c == ' ':
+					buf.WriteByte('_')
+				case isQSafe(c):
+					buf.WriteByte(c)
+				default:
+					fmt.Fprintf(&buf, "=%02X", c)
+				}
+			}
+			words = append(words, "=?utf-8?q?"+buf.String()+"?=")
+		} else {
+			words = append(words, "=?utf-8?b?"+base64.StdEncoding.EncodeToString([]byte(chunk))+"?=")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// isQSafe tells whether c may appear unencoded in a Q-encoded word in a phrase
+// (RFC2047 section 5(3)).
+func isQSafe(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!*+-/", c) >= 0
+}
+
+func qLen(c byte) int {
+	if c == ' ' || isQSafe(c) {
+		return 1
+	}
+	return 3
+}
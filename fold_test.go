@@ -0,0 +1,113 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewField(t *testing.T) {
+	cases := []struct {
+		name, value string
+		want        []string
+	}{{
+		name:  "Subject",
+		value: "hello world",
+		want:  []string{" hello world"},
+	}, {
+		name:  "Subject",
+		value: "café au lait",
+		want:  []string{" =?utf-8?b?Y2Fmw6k=?= au lait"},
+	}, {
+		name:  "Subject",
+		value: "日本語",
+		want:  []string{" =?utf-8?b?5pel5pys6Kqe?="},
+	}, {
+		name:  "Subject",
+		value: "a =?x?q?y?= b",
+		want:  []string{" a =?utf-8?b?PT94P3E/eT89?= b"},
+	}, {
+		name:  "Subject",
+		value: strings.Repeat("abcdefghi ", 10),
+		want: []string{
+			" abcdefghi abcdefghi abcdefghi abcdefghi abcdefghi abcdefghi abcdefghi",
+			" abcdefghi abcdefghi abcdefghi",
+		},
+	}, {
+		name:  "To",
+		value: `"Public, John Q." <jq@example.com>, plain@example.com, Zoë <zoe@example.com>`,
+		want:  []string{` "Public, John Q." <jq@example.com>, plain@example.com,`, ` =?utf-8?q?Zo=C3=AB?= <zoe@example.com>`},
+	}, {
+		name:  "From",
+		value: "Ann Example <ann@example.com>",
+		want:  []string{" Ann Example <ann@example.com>"},
+	}, {
+		name:  "Cc",
+		value: "Zoë <zoe@example.com",
+		want:  []string{" Zoë <zoe@example.com"},
+	}, {
+		name:  "Keywords",
+		value: "urgent, très important",
+		want:  []string{" urgent, =?utf-8?q?tr=C3=A8s_important?="},
+	}, {
+		name:  "Content-Type",
+		value: `text/plain; charset=utf-8 (façade) ; name="a b"`,
+		want:  []string{` text/plain; charset=utf-8 (=?utf-8?q?fa=C3=A7ade?=) ; name="a b"`},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			f := NewField(tc.name, tc.value)
+			if !reflect.DeepEqual(f.V, tc.want) {
+				t.Errorf("got %q, want %q", f.V, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewFieldRoundTrip(t *testing.T) {
+	subject := strings.Repeat("Ünïcödé and plain words mixed together, ", 5)
+	h := &Header{Fields: []*Field{
+		NewField("Subject", subject),
+		NewAddressField("To", []*Address{
+			{Name: "Jöhn \"Q\" Püblic", Address: "jq@example.com"},
+			{Name: "Ann", Address: "ann@example.com"},
+			{Address: "x@example.com"},
+		}),
+	}}
+
+	buf := new(bytes.Buffer)
+	if _, err := h.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if len(line) > foldWidth {
+			t.Errorf("line too long (%d): %s", len(line), line)
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] >= 0x80 {
+				t.Errorf("non-ASCII line: %s", line)
+				break
+			}
+		}
+	}
+
+	h2, err := ReadHeader(bytes.NewReader(buf.Bytes()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h2.Subject(); strings.Join(strings.Fields(got), " ") != strings.Join(strings.Fields(subject), " ") {
+		t.Errorf("got subject %q, want %q", got, subject)
+	}
+	got := h2.Recipients()
+	want := []*Address{
+		{Name: "Jöhn \"Q\" Püblic", Address: "jq@example.com"},
+		{Name: "Ann", Address: "ann@example.com"},
+		{Address: "x@example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got recipients %v, want %v", got, want)
+	}
+}
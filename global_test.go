@@ -0,0 +1,118 @@
+package rmime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGlobal(t *testing.T) {
+	const inp = `From: postmaster@example.com
+Content-Type: multipart/report; report-type=global-delivery-status; boundary=b
+
+--b
+Content-Type: text/plain; charset=utf-8
+
+Zustellung fehlgeschlagen.
+--b
+Content-Type: message/global-delivery-status
+
+Reporting-MTA: dns; mx.example.com
+
+Original-Recipient: utf-8; jörg@bücher.example
+Final-Recipient: utf-8; jörg@bücher.example
+Action: failed
+Status: 5.1.1
+
+--b
+Content-Type: message/global-headers
+
+From: Jörg <jörg@bücher.example>
+Subject: Grüße
+
+--b--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != inp {
+		t.Errorf("message re-rendering mismatch, got:\n%s\n\nwant:\n%s", got, inp)
+	}
+
+	r := m.Report()
+	if r == nil {
+		t.Fatal("no report")
+	}
+	ds := r.DeliveryStatus()
+	if ds == nil {
+		t.Fatal("no delivery status")
+	}
+	if got := ds.PerRecipient()[0].FinalRecipient; got != (TypedValue{Type: "utf-8", Value: "jörg@bücher.example"}) {
+		t.Errorf("got final recipient %v", got)
+	}
+	h, err := r.OriginalHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Subject(); got != "Grüße" {
+		t.Errorf("got original subject %q, want Grüße", got)
+	}
+}
+
+func TestGlobalEncoded(t *testing.T) {
+	const inner = "From: Jörg <jörg@bücher.example>\nSubject: Grüße\n\nHallo!\n"
+
+	enc := base64.StdEncoding.EncodeToString([]byte(inner))
+	inp := "Content-Type: message/global\nContent-Transfer-Encoding: base64\n\n" +
+		enc[:76] + "\n" + enc[76:] + "\n"
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, ok := m.B.(*Message)
+	if !ok {
+		t.Fatalf("got body of type %T, want *Message", m.B)
+	}
+	if got := im.Subject(); got != "Grüße" {
+		t.Errorf("got subject %q, want Grüße", got)
+	}
+	if got := im.B; got != "Hallo!\n" {
+		t.Errorf("got body %q, want Hallo!", got)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != inp {
+		t.Errorf("message re-rendering mismatch, got:\n%s\n\nwant:\n%s", got, inp)
+	}
+}
+
+func TestGlobalHeadersTrailing(t *testing.T) {
+	const inp = "Content-Type: message/global-headers\n\nFrom: Jörg <jörg@bücher.example>\nSubject: Grüße\n\nHallo!\n"
+
+	if _, err := ReadMessage(strings.NewReader(inp)); err == nil {
+		t.Error("got no error in strict mode")
+	}
+
+	m, warnings, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{Lenient: true, PreserveRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnTrailingData {
+		t.Errorf("got warnings %v, want [%s]", warnings, WarnTrailingData)
+	}
+	if got := m.B.(*Header).Subject(); got != "Grüße" {
+		t.Errorf("got subject %s, want Grüße", got)
+	}
+}
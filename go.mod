@@ -6,5 +6,6 @@ toolchain go1.24.2
 
 require (
 	github.com/bobg/errors v1.1.0
+	golang.org/x/net v0.42.0
 	golang.org/x/text v0.27.0
 )
@@ -20,6 +20,11 @@ import (
 type Header struct {
 	Fields      []*Field `json:"fields"`
 	DefaultType string   `json:"default_type"`
+
+	// The line that terminated the header in the input, if recorded (see ParseOptions.PreserveRaw).
+	// This is empty but not nil if the header was terminated by the end of the input
+	// or by a line that is not a field (in lenient mode).
+	end []byte
 }
 
 // Address is the type of an e-mail address.
@@ -36,36 +41,139 @@ var ErrHeaderSyntax = errors.New("bad syntax in header")
 // The defaultType parameter sets the DefaultType field of the resulting Header.
 // Pass "" to get the default defaultType of "text/plain".
 func ReadHeader(r io.ByteReader, defaultType string) (*Header, error) {
+	rr, ok := r.(Reader)
+	if !ok {
+		rr = byteReader{r}
+	}
+	h, _, err := newParser(nil).readHeader(rr, defaultType)
+	return h, err
+}
+
+// readHeader reads a header from r.
+// It returns a reader positioned at the start of the body that follows.
+// This is normally r itself,
+// but in lenient mode it may also include lines that appeared in the header
+// but could not be parsed as fields.
+// On error it returns r,
+// so callers recovering from the error can go on reading the body.
+func (ps *parser) readHeader(r Reader, defaultType string) (*Header, Reader, error) {
+	return ps.readFields(r, defaultType, false)
+}
+
+// readFields does the work of readHeader.
+// If eofOK is true,
+// the end of the input may terminate the header in place of a blank line,
+// as with the last group of fields in a message/delivery-status body.
+func (ps *parser) readFields(r Reader, defaultType string, eofOK bool) (*Header, Reader, error) {
 	if defaultType == "" {
 		defaultType = "text/plain"
 	}
 	result := &Header{DefaultType: defaultType}
-	var latestField *Field
+	var (
+		latestField *Field
+		truncated   bool
+		size        int
+	)
+	setEnd := func(end []byte) {
+		if ps.opts.PreserveRaw {
+			result.end = end
+		}
+	}
 	for {
-		line, err := readHeaderFieldLine(r)
+		maxLine := -1
+		if ps.opts.MaxHeaderBytes > 0 {
+			maxLine = ps.opts.MaxHeaderBytes - size
+		}
+		lineStart := ps.pos(r)
+		raw, err := readRawLine(r, maxLine)
+		if errors.Is(err, errLineTooLong) {
+			return nil, r, &LimitError{Limit: "MaxHeaderBytes", Value: int64(ps.opts.MaxHeaderBytes)}
+		}
+		size += len(raw)
+		if errors.Is(err, io.EOF) && (ps.opts.Lenient || eofOK) && (len(raw) > 0 || len(result.Fields) > 0) {
+			// (An empty input is still an error, io.EOF,
+			// so callers can detect the end of a sequence of headers.)
+			if !truncated && !eofOK {
+				ps.warn(WarnTruncatedHeader, "input ended in the header")
+			}
+			truncated = true
+			if len(raw) == 0 {
+				setEnd([]byte{})
+				return result, r, nil
+			}
+			// Process this final partial line.
+			// The next call to readRawLine will return io.EOF with no data.
+			err = nil
+		}
 		if err != nil {
-			return nil, err
+			return nil, r, err
 		}
+		line := trimEOL(raw)
 		if len(line) == 0 {
-			return result, nil
+			setEnd(raw)
+			return result, r, nil
 		}
 		if isContinuationLine(line) {
 			if latestField == nil {
-				return nil, errors.Wrapf(ErrHeaderSyntax, "unexpected continuation line")
+				if ps.opts.Lenient {
+					ps.warn(WarnOrphanContinuation, "continuation line before any field")
+					setEnd([]byte{})
+					return result, ps.pushback(raw, r), nil
+				}
+				return nil, r, errors.Wrapf(ErrHeaderSyntax, "unexpected continuation line")
 			}
 			latestField.V = append(latestField.V, string(line))
+			if ps.opts.PreserveRaw {
+				latestField.raw += string(raw)
+			}
+			if lineStart >= 0 {
+				latestField.span.End = lineStart + int64(len(raw))
+			}
 			continue
 		}
 		split := bytes.SplitN(line, []byte{':'}, 2)
-		if len(split) != 2 {
-			return nil, ErrHeaderSyntax
+		if len(split) != 2 || (ps.opts.Lenient && !isFieldName(split[0])) {
+			if ps.opts.Lenient {
+				ps.warn(WarnHeaderGarbage, "header line %q is not a field", line)
+				setEnd([]byte{})
+				return result, ps.pushback(raw, r), nil
+			}
+			return nil, r, ErrHeaderSyntax
+		}
+		// xxx check that split[0] is a legal field name (done only in lenient mode)
+		if ps.opts.MaxFields > 0 && len(result.Fields) >= ps.opts.MaxFields {
+			return nil, r, &LimitError{Limit: "MaxFields", Value: int64(ps.opts.MaxFields)}
 		}
-		// xxx check that split[0] is a legal field name
 		latestField = &Field{N: string(split[0]), V: []string{string(split[1])}}
+		if ps.opts.PreserveRaw {
+			latestField.raw = string(raw)
+		}
+		if lineStart >= 0 {
+			latestField.span = Span{Start: lineStart, End: lineStart + int64(len(raw))}
+		}
 		result.Fields = append(result.Fields, latestField)
 	}
 }
 
+// pushback returns a reader that produces line and then the rest of r.
+func (ps *parser) pushback(line []byte, r Reader) Reader {
+	return &prefixReader{prefix: line, r: r}
+}
+
+// isFieldName tells whether name consists only of the printable
+// ASCII characters other than colon that RFC5322 permits in field names.
+func isFieldName(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		if c < 33 || c > 126 {
+			return false
+		}
+	}
+	return true
+}
+
 // Type returns the content-type indicated by h in canonical form.
 func (h Header) Type() string {
 	f := h.findField("Content-Type")
@@ -164,12 +272,12 @@ var trailingParenTextRegex = regexp.MustCompile(`\s*\([^()]*\)\s*$`)
 // Time returns the parsed time of h, or the zero time if absent or
 // unparseable.
 func (h Header) Time() time.Time {
-	f := h.findField("Date") // xxx Resent-Date?
-	if f == nil {
-		return time.Time{}
-	}
-	v := f.Value()
+	return h.dateField("Date") // xxx Resent-Date?
+}
 
+// parseDate parses an RFC5322 date-time,
+// returning the zero time if v is unparseable.
+func parseDate(v string) time.Time {
 	t, err := mail.ParseDate(v)
 	if err == nil {
 		return t
@@ -184,85 +292,87 @@ func (h Header) Time() time.Time {
 	return t
 }
 
-// Encoding returns the content-transfer-encoding indicated by h.
+// Encoding returns the content-transfer-encoding indicated by h,
+// in canonical (lowercase) form.
 func (h Header) Encoding() string {
 	f := h.findField("Content-Transfer-Encoding")
 	if f == nil {
 		return "7bit"
 	}
-	return f.Value()
+	return strings.ToLower(stripComments(f.Value()))
 }
 
-// Sender returns the parsed sender address.
+// Sender returns the parsed sender address:
+// the first address in the From field,
+// parsed leniently (see ParseAddressListLenient).
+// It returns nil if there is none.
+// See also From,
+// and SenderField for the Sender field.
 func (h Header) Sender() *Address {
-	f := h.findField("From") // xxx Resent-From? Sender?
-	if f == nil {
-		return nil
-	}
-	a, err := mail.ParseAddress(f.Value())
-	if err != nil {
-		return nil
-	}
-	return &Address{Name: a.Name, Address: a.Address}
+	return h.firstAddress("From") // xxx Resent-From?
 }
 
 var recipientFields = []string{"To", "Cc", "Bcc"} // xxx Resent-To, Resent-Cc, Resent-Bcc?
 
-// Recipients returns the parsed recipient addresses.
+// Recipients returns the parsed recipient addresses,
+// including the members of groups.
+// Fields are parsed leniently (see ParseAddressListLenient);
+// for strict parsing see To, Cc, and Bcc.
 func (h Header) Recipients() []*Address {
 	var res []*Address
 	for _, name := range recipientFields {
-		f := h.findField(name)
-		if f == nil {
-			continue
-		}
-		as, err := mail.ParseAddressList(f.Value())
-		if err != nil {
-			continue
-		}
-		for _, a := range as {
-			res = append(res, &Address{Name: a.Name, Address: a.Address})
+		for _, v := range h.fieldValues(name) {
+			list, _ := ParseAddressListLenient(v)
+			res = append(res, list.Addresses()...)
 		}
 	}
 	return res
 }
 
+// findField returns the last field in h with the given name
+// (compared case-insensitively),
+// or nil if there is none.
+// Where a field that should appear only once is duplicated,
+// this is the one that the accessors for specific fields use.
+// (Get, by contrast, uses the first.)
 func (h Header) findField(name string) *Field {
 	for i := len(h.Fields) - 1; i >= 0; i-- {
-		if h.Fields[i].Name() == name {
+		if strings.EqualFold(strings.TrimSpace(h.Fields[i].N), name) {
 			return h.Fields[i]
 		}
 	}
 	return nil
 }
 
-// Requires and removes a terminating \n (and an optional preceding
-// \r).
-func readHeaderFieldLine(r io.ByteReader) ([]byte, error) {
-	var (
-		result    []byte
-		pendingCR bool
-	)
+var errLineTooLong = errors.New("line too long")
+
+// readRawLine reads a line from r, including its terminating \n.
+// At the end of the input it returns any partial line along with io.EOF.
+// If max is non-negative and the line is longer than max bytes,
+// it returns errLineTooLong.
+func readRawLine(r io.ByteReader, max int) ([]byte, error) {
+	var result []byte
 	for {
 		b, err := r.ReadByte()
 		if err != nil {
-			return nil, err
+			return result, err
 		}
+		if max >= 0 && len(result) >= max {
+			return nil, errLineTooLong
+		}
+		result = append(result, b)
 		if b == '\n' {
 			return result, nil
 		}
-		if pendingCR {
-			result = append(result, '\r')
-			pendingCR = false
-		}
-		if b == '\r' {
-			pendingCR = true
-		} else {
-			result = append(result, b)
-		}
 	}
 }
 
+// trimEOL removes a terminating \n (and an optional preceding \r) from line.
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'})
+}
+
 func isContinuationLine(line []byte) bool {
 	return len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
 }
@@ -279,7 +389,7 @@ var msgIDRegex = regexp.MustCompile(`<([^<>]+@[^<>]+)>`)
 // msg-id; the msg-id is what is contained between the two angle
 // bracket characters."
 func (h Header) MessageID() string {
-	f := h.findField("Message-Id")
+	f := h.findField("Message-ID")
 	if f == nil {
 		return ""
 	}
@@ -314,3 +424,204 @@ func (h Header) messageIDs(fieldName string) []string {
 	}
 	return result
 }
+
+// stripComments removes RFC5322 comments (parenthesized text,
+// which may nest) from s,
+// leaving quoted strings intact,
+// and trims surrounding whitespace.
+func stripComments(s string) string {
+	var (
+		buf      strings.Builder
+		depth    int
+		inQuotes bool
+		escaped  bool
+	)
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+			if depth > 0 {
+				continue
+			}
+		case c == '\\' && (inQuotes || depth > 0):
+			escaped = true
+			if depth > 0 {
+				continue
+			}
+		case inQuotes:
+			if c == '"' {
+				inQuotes = false
+			}
+		case c == '(':
+			depth++
+			continue
+		case c == ')' && depth > 0:
+			depth--
+			if depth == 0 {
+				buf.WriteByte(' ')
+			}
+			continue
+		case depth > 0:
+			continue
+		case c == '"':
+			inQuotes = true
+		}
+		buf.WriteRune(c)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// fieldValue returns the value of the named field (see Field.Value),
+// or "" if it is absent.
+func (h Header) fieldValue(name string) string {
+	f := h.findField(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value()
+}
+
+// dateField returns the parsed date in the named field,
+// or the zero time if it is absent or unparseable.
+func (h Header) dateField(name string) time.Time {
+	f := h.findField(name)
+	if f == nil {
+		return time.Time{}
+	}
+	return parseDate(f.Value())
+}
+
+// fieldValues returns the values of all the fields in h with the given name,
+// in order.
+func (h Header) fieldValues(name string) []string {
+	var result []string
+	for _, f := range h.Fields {
+		if strings.EqualFold(strings.TrimSpace(f.N), name) {
+			result = append(result, f.Value())
+		}
+	}
+	return result
+}
+
+// Get returns the value of the first field in h with the given name
+// (compared case-insensitively),
+// with RFC2047 encoded-words decoded,
+// or "" if there is none.
+// Unlike the accessors for specific fields,
+// such as Subject,
+// it does no other parsing;
+// and where those use the last of duplicated fields,
+// Get (like GetAll, and as in RFC5322 practice) starts from the first.
+func (h Header) Get(name string) string {
+	for _, f := range h.Fields {
+		if strings.EqualFold(strings.TrimSpace(f.N), name) {
+			return decodeValue(f.Value())
+		}
+	}
+	return ""
+}
+
+// GetAll returns the values of all the fields in h with the given name,
+// in order,
+// decoded as in Get.
+func (h Header) GetAll(name string) []string {
+	var result []string
+	for _, v := range h.fieldValues(name) {
+		result = append(result, decodeValue(v))
+	}
+	return result
+}
+
+func decodeValue(v string) string {
+	dec := mime.WordDecoder{
+		CharsetReader: charsetReader,
+	}
+	res, err := dec.DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return res
+}
+
+// Set sets the field with the given name to the given value,
+// encoding and folding it as in NewField.
+// It replaces the first existing field with that name,
+// keeping its position and the spelling of its name,
+// and removes any others.
+// If there is no such field,
+// it adds one at the end of h.
+//
+// In this and the other methods that add or rename fields,
+// the names of well-known fields,
+// and names given all in lowercase or all in uppercase,
+// are put in canonical form (see CanonicalName);
+// other names are used as given.
+func (h *Header) Set(name, value string) {
+	var (
+		fields []*Field
+		found  bool
+	)
+	for _, old := range h.Fields {
+		if !strings.EqualFold(strings.TrimSpace(old.N), name) {
+			fields = append(fields, old)
+			continue
+		}
+		if !found {
+			fields = append(fields, NewField(old.N, value))
+			found = true
+		}
+	}
+	if !found {
+		fields = append(fields, NewField(knownName(name), value))
+	}
+	h.Fields = fields
+}
+
+// knownName returns the canonical form of name
+// if it is a well-known field name or is all in one case,
+// otherwise name itself.
+func knownName(name string) string {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+	if _, ok := canonicalNames[lower]; ok || name == lower || name == strings.ToUpper(name) {
+		return CanonicalName(name)
+	}
+	return name
+}
+
+// Add adds a field with the given name and value to the end of h,
+// encoding and folding the value as in NewField.
+func (h *Header) Add(name, value string) {
+	h.Fields = append(h.Fields, NewField(knownName(name), value))
+}
+
+// Insert adds a field with the given name and value to the top of h,
+// encoding and folding the value as in NewField.
+// This is where trace fields (Received, Return-Path)
+// and signatures (DKIM-Signature) belong.
+func (h *Header) Insert(name, value string) {
+	h.Fields = append([]*Field{NewField(knownName(name), value)}, h.Fields...)
+}
+
+// Del removes all fields with the given name from h.
+func (h *Header) Del(name string) {
+	var fields []*Field
+	for _, f := range h.Fields {
+		if !strings.EqualFold(strings.TrimSpace(f.N), name) {
+			fields = append(fields, f)
+		}
+	}
+	h.Fields = fields
+}
+
+// Rename changes the name of all fields in h named oldName to newName,
+// leaving their values and positions unchanged.
+// It is useful for disabling a field while keeping its contents,
+// e.g. renaming DKIM-Signature to X-Original-DKIM-Signature.
+func (h *Header) Rename(oldName, newName string) {
+	for _, f := range h.Fields {
+		if strings.EqualFold(strings.TrimSpace(f.N), oldName) {
+			f.N = knownName(newName)
+		}
+	}
+}
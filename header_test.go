@@ -0,0 +1,117 @@
+package rmime
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaderEdit(t *testing.T) {
+	const inp = `Received: from a by b
+Received: from c by d
+Subject: =?utf-8?q?caf=C3=A9?=
+X-Tag: one
+x-tag: two
+DKIM-Signature: v=1; d=example.com
+
+`
+	h, err := ReadHeader(strings.NewReader(inp), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.Get("subject"); got != "café" {
+		t.Errorf("got subject %q, want café", got)
+	}
+	if got := h.Get("Received"); got != "from a by b" {
+		t.Errorf("got Received %q, want the first one", got)
+	}
+	if got := h.Get("X-Missing"); got != "" {
+		t.Errorf("got %q for a missing field", got)
+	}
+	if got, want := h.GetAll("X-TAG"), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	h.Set("x-tag", "three")
+	h.Set("Message-Id", "<new@example.com>")
+	h.Insert("received", "from e by f")
+	h.Add("Comments", "naïve")
+	h.Del("Subject")
+	h.Rename("dkim-signature", "X-Original-DKIM-Signature")
+
+	buf := new(bytes.Buffer)
+	if _, err := h.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	const want = `Received: from e by f
+Received: from a by b
+Received: from c by d
+X-Tag: three
+X-Original-DKIM-Signature: v=1; d=example.com
+Message-ID: <new@example.com>
+Comments: =?utf-8?b?bmHDr3Zl?=
+
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := h.MessageID(); got != "new@example.com" {
+		t.Errorf("got message-id %q", got)
+	}
+}
+
+func TestHeaderDuplicateFields(t *testing.T) {
+	const inp = `Content-Type: text/html; charset=utf-8
+Content-Type: image/png
+Content-Transfer-Encoding: 7bit
+Content-Transfer-Encoding: base64
+Content-Disposition: inline
+Content-Disposition: attachment; filename=x.png
+Date: Mon, 06 Jan 2020 10:00:05 -0800
+Date: Tue, 07 Jan 2020 10:00:05 -0800
+Subject: first
+Subject: second
+
+`
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The accessors for specific fields use the last of duplicates.
+	if got := m.Type(); got != "image/png" {
+		t.Errorf("got type %s, want image/png", got)
+	}
+	if got := m.Encoding(); got != "base64" {
+		t.Errorf("got encoding %s, want base64", got)
+	}
+	if got, _ := m.Disposition(); got != "attachment" {
+		t.Errorf("got disposition %s, want attachment", got)
+	}
+	if got := m.Time(); got.Day() != 7 {
+		t.Errorf("got date %s, want the second one", got)
+	}
+	if got := m.Subject(); got != "second" {
+		t.Errorf("got subject %q, want second", got)
+	}
+	bs, err := m.BodyStructure(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bs, `("IMAGE" "PNG"`) {
+		t.Errorf("got body structure %s, want image/png", bs)
+	}
+	if env := m.Envelope(); !strings.Contains(env, `"second"`) {
+		t.Errorf("got envelope %s, want the second subject", env)
+	}
+
+	// Get uses the first.
+	if got := m.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("got Content-Type %q, want the first one", got)
+	}
+	if got := m.Get("Subject"); got != "first" {
+		t.Errorf("got Subject %q, want first", got)
+	}
+}
@@ -0,0 +1,315 @@
+package rmime
+
+import (
+	"bytes"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bobg/errors"
+)
+
+// BodyStructure returns the IMAP BODYSTRUCTURE of m
+// (RFC3501 section 7.4.2, RFC9051 section 7.5.2).
+// If ext is false,
+// it omits the extension data,
+// producing the IMAP BODY form instead.
+//
+// Sizes are in octets of m rendered in canonical CRLF form,
+// as an IMAP server presents it.
+// The line ending before a multipart boundary belongs to the boundary,
+// not to the preceding part.
+// The body of a message/global part is described like that of message/rfc822.
+func (m *Message) BodyStructure(ext bool) (string, error) {
+	buf := new(strings.Builder)
+	err := (*Part)(m).bodyStructure(buf, ext, false)
+	return buf.String(), err
+}
+
+// Envelope returns the IMAP ENVELOPE of h
+// (RFC3501 section 7.4.2, RFC9051 section 7.5.2).
+// Field values appear as they are in h,
+// without decoding RFC2047 encoded-words,
+// except that display names in addresses are re-encoded if necessary.
+func (h Header) Envelope() string {
+	buf := new(strings.Builder)
+	h.writeEnvelope(buf)
+	return buf.String()
+}
+
+// bodyStructure writes the BODYSTRUCTURE of p to buf.
+// If bounded is true,
+// p is followed by a multipart boundary,
+// so the final line ending in its content is not counted.
+func (p *Part) bodyStructure(buf *strings.Builder, ext, bounded bool) error {
+	params := p.Params()
+	if p.MajorType() == "text" && params["charset"] == "" {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params["charset"] = "us-ascii"
+	}
+
+	buf.WriteByte('(')
+
+	if mp, ok := p.B.(*Multipart); ok {
+		for i, subpart := range mp.Parts {
+			if err := subpart.bodyStructure(buf, ext, true); err != nil {
+				return errors.Wrapf(err, "in part %d", i+1)
+			}
+		}
+		buf.WriteByte(' ')
+		writeIMAPString(buf, strings.ToUpper(p.MinorType()))
+		if ext {
+			buf.WriteByte(' ')
+			writeIMAPParams(buf, params)
+			p.writeIMAPExt(buf)
+		}
+		buf.WriteByte(')')
+		return nil
+	}
+
+	body := new(bytes.Buffer)
+	if _, err := p.writeBody(body); err != nil {
+		return errors.Wrap(err, "rendering body")
+	}
+	b := body.Bytes()
+	if bounded {
+		b = trimEOL(b)
+	}
+	size, lines := imapSize(b)
+
+	writeIMAPString(buf, strings.ToUpper(p.MajorType()))
+	buf.WriteByte(' ')
+	writeIMAPString(buf, strings.ToUpper(p.MinorType()))
+	buf.WriteByte(' ')
+	writeIMAPParams(buf, params)
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, p.fieldValue("Content-ID"))
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, p.fieldValue("Content-Description"))
+	buf.WriteByte(' ')
+	writeIMAPString(buf, strings.ToUpper(p.Encoding()))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(size, 10))
+
+	switch inner := p.B.(type) {
+	case *Message:
+		buf.WriteByte(' ')
+		inner.writeEnvelope(buf)
+		buf.WriteByte(' ')
+		if err := (*Part)(inner).bodyStructure(buf, ext, bounded); err != nil {
+			return errors.Wrap(err, "in encapsulated message")
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(lines, 10))
+
+	default:
+		if p.MajorType() == "text" {
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(lines, 10))
+		}
+	}
+
+	if ext {
+		buf.WriteByte(' ')
+		writeIMAPNString(buf, p.fieldValue("Content-MD5"))
+		p.writeIMAPExt(buf)
+	}
+	buf.WriteByte(')')
+	return nil
+}
+
+// writeIMAPExt writes the disposition, language, and location extension data
+// common to multipart and non-multipart parts,
+// each preceded by a space.
+func (p *Part) writeIMAPExt(buf *strings.Builder) {
+	buf.WriteByte(' ')
+	if p.findField("Content-Disposition") == nil {
+		buf.WriteString("NIL")
+	} else {
+		disp, params := p.Disposition()
+		buf.WriteByte('(')
+		writeIMAPString(buf, strings.ToUpper(disp))
+		buf.WriteByte(' ')
+		writeIMAPParams(buf, params)
+		buf.WriteByte(')')
+	}
+
+	buf.WriteByte(' ')
+	var langs []string
+	for _, lang := range strings.Split(stripComments(p.fieldValue("Content-Language")), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	switch len(langs) {
+	case 0:
+		buf.WriteString("NIL")
+	case 1:
+		writeIMAPString(buf, langs[0])
+	default:
+		writeIMAPList(buf, langs)
+	}
+
+	buf.WriteByte(' ')
+	// A long Content-Location may be folded,
+	// and the whitespace is not part of it (RFC2557 section 4.4.1).
+	writeIMAPNString(buf, strings.Join(strings.Fields(p.fieldValue("Content-Location")), ""))
+}
+
+func (h Header) writeEnvelope(buf *strings.Builder) {
+	from := h.findField("From")
+	addrField := func(name string) {
+		buf.WriteByte(' ')
+		f := h.findField(name)
+		if (f == nil || f.Value() == "") && (name == "Sender" || name == "Reply-To") {
+			// RFC3501: "If the Sender or Reply-To lines are absent in the [RFC-2822] header,
+			// or are present but empty,
+			// the server sets the corresponding member of the envelope to be the same value as the from member."
+			f = from
+		}
+		writeIMAPAddrs(buf, f)
+	}
+
+	buf.WriteByte('(')
+	writeIMAPNString(buf, h.fieldValue("Date"))
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, h.fieldValue("Subject"))
+	for _, name := range []string{"From", "Sender", "Reply-To", "To", "Cc", "Bcc"} {
+		addrField(name)
+	}
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, h.fieldValue("In-Reply-To"))
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, h.fieldValue("Message-ID"))
+	buf.WriteByte(')')
+}
+
+func writeIMAPAddrs(buf *strings.Builder, f *Field) {
+	if f == nil {
+		buf.WriteString("NIL")
+		return
+	}
+	list, err := ParseAddressList(f.Value())
+	if err != nil || len(list) == 0 {
+		buf.WriteString("NIL")
+		return
+	}
+	buf.WriteByte('(')
+	for _, item := range list {
+		switch item := item.(type) {
+		case *Address:
+			writeIMAPAddr(buf, item)
+		case *Group:
+			// RFC3501: a group is bracketed by an address with a NIL host name,
+			// whose mailbox name is the group name,
+			// and one with NIL mailbox and host names.
+			buf.WriteString("(NIL NIL ")
+			writeIMAPString(buf, mime.QEncoding.Encode("utf-8", item.Name))
+			buf.WriteString(" NIL)")
+			for _, addr := range item.Members {
+				writeIMAPAddr(buf, addr)
+			}
+			buf.WriteString("(NIL NIL NIL NIL)")
+		}
+	}
+	buf.WriteByte(')')
+}
+
+func writeIMAPAddr(buf *strings.Builder, addr *Address) {
+	mailbox, host := addr.Address, ""
+	if i := strings.LastIndexByte(mailbox, '@'); i >= 0 {
+		mailbox, host = mailbox[:i], mailbox[i+1:]
+	}
+	buf.WriteByte('(')
+	writeIMAPNString(buf, mime.QEncoding.Encode("utf-8", addr.Name))
+	buf.WriteString(" NIL ")
+	writeIMAPNString(buf, mailbox)
+	buf.WriteByte(' ')
+	writeIMAPNString(buf, host)
+	buf.WriteByte(')')
+}
+
+// writeIMAPParams writes params as an IMAP parenthesized list of attribute/value pairs,
+// sorted by attribute,
+// or NIL if params is empty.
+func writeIMAPParams(buf *strings.Builder, params map[string]string) {
+	if len(params) == 0 {
+		buf.WriteString("NIL")
+		return
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var list []string
+	for _, k := range keys {
+		list = append(list, strings.ToUpper(k), params[k])
+	}
+	writeIMAPList(buf, list)
+}
+
+func writeIMAPList(buf *strings.Builder, strs []string) {
+	buf.WriteByte('(')
+	for i, s := range strs {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		writeIMAPString(buf, s)
+	}
+	buf.WriteByte(')')
+}
+
+// writeIMAPNString writes s as an IMAP string,
+// or NIL if it is empty.
+func writeIMAPNString(buf *strings.Builder, s string) {
+	if s == "" {
+		buf.WriteString("NIL")
+		return
+	}
+	writeIMAPString(buf, s)
+}
+
+// writeIMAPString writes s as an IMAP quoted string,
+// or as a literal if it cannot be quoted.
+func writeIMAPString(buf *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == 0 || c == '\r' || c == '\n' || c >= 0x80 {
+			buf.WriteString("{" + strconv.Itoa(len(s)) + "}\r\n")
+			buf.WriteString(s)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+}
+
+// imapSize returns the size of b in octets,
+// counting each bare \n as \r\n,
+// and the number of lines in it,
+// including a final unterminated one.
+func imapSize(b []byte) (size, lines int64) {
+	size = int64(len(b))
+	for i, c := range b {
+		if c != '\n' {
+			continue
+		}
+		lines++
+		if i == 0 || b[i-1] != '\r' {
+			size++
+		}
+	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		lines++
+	}
+	return size, lines
+}
@@ -0,0 +1,106 @@
+package rmime
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBodyStructure(t *testing.T) {
+	const inp = `From: "Joe Q. Public" <joe@example.com>
+To: a@example.com, B <b@example.org>
+Subject: =?utf-8?q?caf=C3=A9?=
+Date: Mon, 7 Feb 1994 21:52:25 -0800
+Message-Id: <outer@example.com>
+Content-Type: multipart/mixed; boundary=b
+
+preamble
+--b
+
+hello
+world
+--b
+Content-Type: application/octet-stream; name="x.bin"
+Content-Transfer-Encoding: base64
+Content-Disposition: attachment; filename="x.bin"
+Content-Language: en, fr
+Content-Location: http://example.com/
+ x.bin
+Content-Md5: Q2hlY2sgSW50ZWdyaXR5IQ==
+
+AAEC
+--b
+Content-Type: message/rfc822
+Content-Description: forwarded
+
+From: Jöe <joe@example.com>
+Subject: inner
+
+inner body
+--b--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ext  bool
+		want string
+	}{{
+		ext: false,
+		want: `(("TEXT" "PLAIN" ("CHARSET" "us-ascii") NIL NIL "7BIT" 12 2)` +
+			`("APPLICATION" "OCTET-STREAM" ("NAME" "x.bin") NIL NIL "BASE64" 4)` +
+			`("MESSAGE" "RFC822" NIL NIL "forwarded" "7BIT" 58 ` +
+			`(NIL "inner" (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) NIL NIL NIL NIL NIL) ` +
+			`("TEXT" "PLAIN" ("CHARSET" "us-ascii") NIL NIL "7BIT" 10 1) 4) "MIXED")`,
+	}, {
+		ext: true,
+		want: `(("TEXT" "PLAIN" ("CHARSET" "us-ascii") NIL NIL "7BIT" 12 2 NIL NIL NIL NIL)` +
+			`("APPLICATION" "OCTET-STREAM" ("NAME" "x.bin") NIL NIL "BASE64" 4 "Q2hlY2sgSW50ZWdyaXR5IQ==" ("ATTACHMENT" ("FILENAME" "x.bin")) ("en" "fr") "http://example.com/x.bin")` +
+			`("MESSAGE" "RFC822" NIL NIL "forwarded" "7BIT" 58 ` +
+			`(NIL "inner" (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) (("=?utf-8?q?J=C3=B6e?=" NIL "joe" "example.com")) NIL NIL NIL NIL NIL) ` +
+			`("TEXT" "PLAIN" ("CHARSET" "us-ascii") NIL NIL "7BIT" 10 1 NIL NIL NIL NIL) 4 NIL NIL NIL NIL) "MIXED" ("BOUNDARY" "b") NIL NIL NIL)`,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := m.BodyStructure(tc.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	const wantEnv = `("Mon, 7 Feb 1994 21:52:25 -0800" "=?utf-8?q?caf=C3=A9?=" ` +
+		`(("Joe Q. Public" NIL "joe" "example.com")) (("Joe Q. Public" NIL "joe" "example.com")) (("Joe Q. Public" NIL "joe" "example.com")) ` +
+		`((NIL NIL "a" "example.com")("B" NIL "b" "example.org")) NIL NIL NIL "<outer@example.com>")`
+	if got := m.Envelope(); got != wantEnv {
+		t.Errorf("got envelope:\n%s\nwant:\n%s", got, wantEnv)
+	}
+}
+
+func TestIMAPSize(t *testing.T) {
+	cases := []struct {
+		inp                 string
+		wantSize, wantLines int64
+	}{
+		{inp: "", wantSize: 0, wantLines: 0},
+		{inp: "a", wantSize: 1, wantLines: 1},
+		{inp: "a\n", wantSize: 3, wantLines: 1},
+		{inp: "a\r\nb", wantSize: 4, wantLines: 2},
+		{inp: "\n\r\n", wantSize: 4, wantLines: 2},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			size, lines := imapSize([]byte(tc.inp))
+			if size != tc.wantSize || lines != tc.wantLines {
+				t.Errorf("got %d, %d; want %d, %d", size, lines, tc.wantSize, tc.wantLines)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package rmime
+
+import "strings"
+
+// DispositionNotification is the type of a parsed message/disposition-notification body part,
+// the machine-readable part of a message disposition notification,
+// or MDN (RFC8098).
+// Its Header holds the raw fields;
+// see Notification for their parsed contents.
+type DispositionNotification struct {
+	Header *Header `json:"header"`
+}
+
+// Notification holds the parsed fields of a message disposition notification.
+// Absent fields have zero values.
+type Notification struct {
+	ReportingUA        string         `json:"reporting_ua,omitempty"`         // The ua-name part of Reporting-UA.
+	ReportingUAProduct string         `json:"reporting_ua_product,omitempty"` // The ua-product part of Reporting-UA.
+	MDNGateway         TypedValue     `json:"mdn_gateway,omitempty"`
+	OriginalRecipient  TypedValue     `json:"original_recipient,omitempty"`
+	FinalRecipient     TypedValue     `json:"final_recipient"`
+	OriginalMessageID  string         `json:"original_message_id,omitempty"` // Without angle brackets (see Header.MessageID).
+	Disposition        MDNDisposition `json:"disposition"`
+	Errors             []string       `json:"errors,omitempty"` // From any Error fields.
+}
+
+// MDNDisposition is the parsed Disposition field of a message disposition notification.
+// All its parts are canonicalized to lowercase.
+type MDNDisposition struct {
+	ActionMode  string   `json:"action_mode"`         // "manual-action" or "automatic-action".
+	SendingMode string   `json:"sending_mode"`        // "mdn-sent-manually" or "mdn-sent-automatically".
+	Type        string   `json:"type"`                // "displayed", "deleted", "dispatched", or "processed".
+	Modifiers   []string `json:"modifiers,omitempty"` // E.g. "error".
+}
+
+// Notification parses the fields of dn.
+func (dn *DispositionNotification) Notification() *Notification {
+	h := dn.Header
+	if h == nil {
+		return &Notification{}
+	}
+	result := &Notification{
+		MDNGateway:        parseTypedValue(h.fieldValue("MDN-Gateway")),
+		OriginalRecipient: parseTypedValue(h.fieldValue("Original-Recipient")),
+		FinalRecipient:    parseTypedValue(h.fieldValue("Final-Recipient")),
+		Disposition:       parseMDNDisposition(h.fieldValue("Disposition")),
+	}
+
+	name, product, _ := strings.Cut(h.fieldValue("Reporting-UA"), ";")
+	result.ReportingUA = strings.TrimSpace(name)
+	result.ReportingUAProduct = strings.TrimSpace(product)
+
+	if m := msgIDRegex.FindStringSubmatch(h.fieldValue("Original-Message-ID")); len(m) > 0 {
+		result.OriginalMessageID = m[1]
+	}
+
+	result.Errors = h.fieldValues("Error")
+	return result
+}
+
+// parseMDNDisposition parses the value of a Disposition field:
+//
+//	action-mode "/" sending-mode ";" disposition-type [ "/" modifier *( "," modifier ) ]
+func parseMDNDisposition(v string) MDNDisposition {
+	var result MDNDisposition
+	v = strings.ToLower(stripComments(v))
+	mode, typ, _ := strings.Cut(v, ";")
+	action, sending, _ := strings.Cut(mode, "/")
+	result.ActionMode = strings.TrimSpace(action)
+	result.SendingMode = strings.TrimSpace(sending)
+	typ, mods, ok := strings.Cut(typ, "/")
+	result.Type = strings.TrimSpace(typ)
+	if ok {
+		for _, mod := range strings.Split(mods, ",") {
+			if mod = strings.TrimSpace(mod); mod != "" {
+				result.Modifiers = append(result.Modifiers, mod)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package rmime
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDispositionNotification(t *testing.T) {
+	const inp = `Content-Type: message/disposition-notification
+
+Reporting-UA: joes-pc.cs.example.com; Foomail 97.1
+Original-Recipient: rfc822;Joe_Recipient@example.com
+Final-Recipient: rfc822;Joe_Recipient@example.com
+Original-Message-ID: <199509192301.23456@example.org>
+Disposition: manual-action/MDN-sent-manually; displayed/error,
+ x-other (comment)
+Error: something
+ went wrong
+
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dn, ok := m.B.(*DispositionNotification)
+	if !ok {
+		t.Fatalf("got body of type %T, want *DispositionNotification", m.B)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != inp {
+		t.Errorf("message re-rendering mismatch, got:\n%s\n\nwant:\n%s", got, inp)
+	}
+
+	got := dn.Notification()
+	want := &Notification{
+		ReportingUA:        "joes-pc.cs.example.com",
+		ReportingUAProduct: "Foomail 97.1",
+		OriginalRecipient:  TypedValue{Type: "rfc822", Value: "Joe_Recipient@example.com"},
+		FinalRecipient:     TypedValue{Type: "rfc822", Value: "Joe_Recipient@example.com"},
+		OriginalMessageID:  "199509192301.23456@example.org",
+		Disposition: MDNDisposition{
+			ActionMode:  "manual-action",
+			SendingMode: "mdn-sent-manually",
+			Type:        "displayed",
+			Modifiers:   []string{"error", "x-other"},
+		},
+		Errors: []string{"something went wrong"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDispositionNotificationTrailing(t *testing.T) {
+	const inp = "Content-Type: message/disposition-notification\n\nFinal-Recipient: rfc822; a@example.com\nDisposition: automatic-action/MDN-sent-automatically; deleted\n\nmore text\n"
+
+	if _, err := ReadMessage(strings.NewReader(inp)); err == nil {
+		t.Error("got no error in strict mode")
+	}
+
+	m, warnings, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{Lenient: true, PreserveRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnTrailingData {
+		t.Errorf("got warnings %v, want [%s]", warnings, WarnTrailingData)
+	}
+	if got := m.B.(*DispositionNotification).Notification().Disposition.Type; got != "deleted" {
+		t.Errorf("got disposition type %s, want deleted", got)
+	}
+
+	// Trailing blank lines are fine.
+	if _, err := ReadMessage(strings.NewReader(strings.Replace(inp, "more text\n", "\n\n", 1))); err != nil {
+		t.Error(err)
+	}
+}
@@ -14,6 +14,28 @@ type Reader interface {
 
 // ReadMessage reads a message from r.
 func ReadMessage(r Reader) (*Message, error) {
-	part, err := ReadPart(r, nil)
+	m, _, err := ReadMessageWithOptions(r, nil)
+	return m, err
+}
+
+// ReadMessageWithOptions reads a message from r as directed by opts,
+// which may be nil.
+// It also returns the warnings,
+// if any,
+// produced by recovering from malformed input in lenient mode.
+func ReadMessageWithOptions(r Reader, opts *ParseOptions) (*Message, []*Warning, error) {
+	ps := newParser(opts)
+	if ps.opts.RecordOffsets {
+		r = &offsetReader{r: r}
+	}
+	m, err := ps.readMessage(r)
+	if m != nil {
+		m.Renumber()
+	}
+	return m, ps.warnings, err
+}
+
+func (ps *parser) readMessage(r Reader) (*Message, error) {
+	part, err := ps.readPart(r, nil)
 	return (*Message)(part), err
 }
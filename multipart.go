@@ -1,10 +1,8 @@
 package rmime
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime"
 )
 
@@ -13,106 +11,90 @@ import (
 type Multipart struct {
 	Preamble, Postamble string
 	Parts               []*Part
+
+	// The boundary lines in the input,
+	// including the final one,
+	// and the boundary they contain,
+	// if recorded (see ParseOptions.PreserveRaw).
+	delims   []string
+	boundary string
 }
 
-func readMultipart(r io.Reader, header *Header) (*Multipart, error) {
+func (ps *parser) readMultipart(r Reader, header *Header) (interface{}, error) {
 	f := header.findField("Content-Type")
 	if f == nil {
 		return nil, fmt.Errorf("no Content-Type field in multipart header")
 	}
 	_, params, err := mime.ParseMediaType(f.Value())
-	if err != nil {
+	if err != nil && !ps.opts.Lenient {
 		return nil, err
 	}
 	boundary := params["boundary"]
 	if boundary == "" {
+		if ps.opts.Lenient {
+			ps.warn(WarnMissingBoundaryParam, "no boundary parameter for %s, reading as text", header.Type())
+			return readString(ps.bodyReader(r))
+		}
 		return nil, fmt.Errorf("no boundary parameter in multipart Content-Type field")
 	}
-	preamble, isFinal, err := readUntilBoundary(r, boundary)
+	mr := newMultipartReader(r, boundary)
+	mr.lenient = ps.opts.Lenient
+	mr.start = ps.pos(r)
+	mr.keepDelims = ps.opts.PreserveRaw
+	pr, err := mr.nextPart()
+	if err != nil {
+		return nil, err
+	}
+	preamble, err := io.ReadAll(ps.bodyReader(pr))
 	if err != nil {
 		return nil, err
 	}
-	if isFinal {
+	if pr.truncated {
+		ps.warn(WarnNoBoundary, "no boundary line found in %s, reading as text", header.Type())
+		return string(preamble), nil
+	}
+	delims := []string{string(pr.delim)}
+	if pr.final {
+		if ps.opts.Lenient {
+			ps.warn(WarnEmptyMultipart, "final multipart boundary encountered before any others")
+			return ps.finishMultipart(mr, boundary, preamble, nil, delims)
+		}
 		return nil, fmt.Errorf("final multipart boundary encountered before any others")
 	}
 	var parts []*Part
 	for {
-		content, isFinal, err := readUntilBoundary(r, boundary)
+		pr, err := mr.nextPart()
 		if err != nil {
 			return nil, err
 		}
-		inner := bytes.NewReader(content)
-		part, err := ReadPart(inner, header)
+		part, err := ps.readPart(pr, header)
 		if err != nil {
 			return nil, err
 		}
 		parts = append(parts, part)
-		if isFinal {
-			postamble, err := ioutil.ReadAll(r)
-			if err != nil {
-				return nil, err
-			}
-			return &Multipart{Preamble: string(preamble), Postamble: string(postamble), Parts: parts}, nil
-		}
-	}
-}
 
-func readUntilBoundary(r io.Reader, boundary string) ([]byte, bool, error) {
-	var result []byte
-	for {
-		line, err := readLine(r)
-		if err != nil {
-			return nil, false, err
+		// Skip anything the part didn't consume, to learn whether the boundary was the final one.
+		if _, err := io.Copy(io.Discard, pr); err != nil {
+			return nil, err
 		}
-		if match, final := isBoundary(line, boundary); match {
-			return result, final, nil
+		if pr.truncated {
+			ps.warn(WarnUnterminatedMultipart, "input ended before the final boundary of %s", header.Type())
 		}
-		result = append(result, line...)
-	}
-}
-
-func isBoundary(line []byte, boundary string) (match bool, final bool) {
-	if len(line) < len(boundary)+2 {
-		return false, false
-	}
-	if !bytes.Equal(line[:2], []byte("--")) {
-		return false, false
-	}
-	if !bytes.Equal(line[2:2+len(boundary)], []byte(boundary)) {
-		return false, false
-	}
-	rest := line[2+len(boundary):]
-	if len(rest) >= 2 && bytes.Equal(rest[:2], []byte("--")) {
-		final = true
-		rest = rest[2:]
-	}
-	// allow only LWSP and \r?\n
-	if rest[len(rest)-1] == '\n' {
-		rest = rest[:len(rest)-1]
-	}
-	for _, r := range rest {
-		switch r {
-		case ' ', '\f', '\r', '\t', '\v':
-			// ignore
-		default:
-			return false, false
+		delims = append(delims, string(pr.delim))
+		if pr.final {
+			return ps.finishMultipart(mr, boundary, preamble, parts, delims)
 		}
 	}
-	return true, final
 }
 
-// Requires and includes a terminating newline.
-func readLine(r io.Reader) ([]byte, error) {
-	var result []byte
-	for {
-		var b [1]byte
-		_, err := io.ReadFull(r, b[:])
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, b[0])
-		if b[0] == '\n' {
-			return result, nil
-		}
+func (ps *parser) finishMultipart(mr *multipartReader, boundary string, preamble []byte, parts []*Part, delims []string) (*Multipart, error) {
+	postamble, err := io.ReadAll(ps.bodyReader(mr.rest()))
+	if err != nil {
+		return nil, err
+	}
+	result := &Multipart{Preamble: string(preamble), Postamble: string(postamble), Parts: parts}
+	if mr.keepDelims {
+		result.delims, result.boundary = delims, boundary
 	}
+	return result, nil
 }
@@ -0,0 +1,190 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadMultipart(t *testing.T) {
+	cases := []struct {
+		inp       string
+		preamble  string
+		parts     []string
+		postamble string
+		wantErr   bool
+	}{{
+		inp:       "pre\n--b\n\none\n--b \t\r\n\ntwo\r\n--b--\npost\n",
+		preamble:  "pre\n",
+		parts:     []string{"one\n", "two\r\n"},
+		postamble: "post\n",
+	}, {
+		inp:   "--b\n\none\n--bb\n--b-\n--b--",
+		parts: []string{"one\n--bb\n--b-\n"},
+	}, {
+		inp:     "--b\n\none\n",
+		wantErr: true,
+	}, {
+		inp:     "--b--\n",
+		wantErr: true,
+	}}
+
+	h := &Header{Fields: []*Field{{N: "Content-Type", V: []string{" multipart/mixed; boundary=b"}}}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			b, err := newParser(nil).readMultipart(strings.NewReader(tc.inp), h)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("got no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := b.(*Multipart)
+			if m.Preamble != tc.preamble {
+				t.Errorf("got preamble %q, want %q", m.Preamble, tc.preamble)
+			}
+			if m.Postamble != tc.postamble {
+				t.Errorf("got postamble %q, want %q", m.Postamble, tc.postamble)
+			}
+			if len(m.Parts) != len(tc.parts) {
+				t.Fatalf("got %d parts, want %d", len(m.Parts), len(tc.parts))
+			}
+			for j, p := range m.Parts {
+				if p.B != tc.parts[j] {
+					t.Errorf("part %d: got %q, want %q", j+1, p.B, tc.parts[j])
+				}
+			}
+		})
+	}
+}
+
+// benchMultipart builds a multipart body with the given number of parts,
+// each containing the given number of 76-character lines.
+func benchMultipart(nparts, nlines int) []byte {
+	line := strings.Repeat("x", 75) + "\n"
+	buf := new(bytes.Buffer)
+	buf.WriteString("preamble\n")
+	for i := 0; i < nparts; i++ {
+		buf.WriteString("--boundary-0123456789\nContent-Type: application/octet-stream\n\n")
+		for j := 0; j < nlines; j++ {
+			buf.WriteString(line)
+		}
+	}
+	buf.WriteString("--boundary-0123456789--\n")
+	return buf.Bytes()
+}
+
+func BenchmarkSplitMultipart(b *testing.B) {
+	inp := benchMultipart(20, 1000)
+
+	b.Run("scanner", func(b *testing.B) {
+		b.SetBytes(int64(len(inp)))
+		for i := 0; i < b.N; i++ {
+			mr := newMultipartReader(bytes.NewReader(inp), "boundary-0123456789")
+			for {
+				pr, err := mr.nextPart()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, pr); err != nil {
+					b.Fatal(err)
+				}
+				if pr.final {
+					break
+				}
+			}
+		}
+	})
+
+	b.Run("legacy", func(b *testing.B) {
+		b.SetBytes(int64(len(inp)))
+		for i := 0; i < b.N; i++ {
+			r := bytes.NewReader(inp)
+			for {
+				_, final, err := legacyReadUntilBoundary(r, "boundary-0123456789")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if final {
+					break
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkReadMessage(b *testing.B) {
+	inp := append([]byte("Content-Type: multipart/mixed; boundary=boundary-0123456789\n\n"), benchMultipart(20, 1000)...)
+	b.SetBytes(int64(len(inp)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadMessage(bytes.NewReader(inp)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// What follows is the line-at-a-time boundary detection that readMultipart formerly used,
+// retained here for comparison in BenchmarkSplitMultipart.
+
+func legacyReadUntilBoundary(r io.Reader, boundary string) ([]byte, bool, error) {
+	var result []byte
+	for {
+		line, err := legacyReadLine(r)
+		if err != nil {
+			return nil, false, err
+		}
+		if match, final := legacyIsBoundary(line, boundary); match {
+			return result, final, nil
+		}
+		result = append(result, line...)
+	}
+}
+
+func legacyIsBoundary(line []byte, boundary string) (match bool, final bool) {
+	if len(line) < len(boundary)+2 {
+		return false, false
+	}
+	if !bytes.Equal(line[:2], []byte("--")) {
+		return false, false
+	}
+	if !bytes.Equal(line[2:2+len(boundary)], []byte(boundary)) {
+		return false, false
+	}
+	rest := line[2+len(boundary):]
+	if len(rest) >= 2 && bytes.Equal(rest[:2], []byte("--")) {
+		final = true
+		rest = rest[2:]
+	}
+	if rest[len(rest)-1] == '\n' {
+		rest = rest[:len(rest)-1]
+	}
+	for _, r := range rest {
+		switch r {
+		case ' ', '\f', '\r', '\t', '\v':
+		default:
+			return false, false
+		}
+	}
+	return true, final
+}
+
+func legacyReadLine(r io.Reader) ([]byte, error) {
+	var result []byte
+	for {
+		var b [1]byte
+		_, err := io.ReadFull(r, b[:])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b[0])
+		if b[0] == '\n' {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package rmime
+
+// Span is a range of byte offsets within the input to ReadMessageWithOptions,
+// from Start (inclusive) to End (exclusive).
+// Offsets are relative to the position of the input when parsing began.
+type Span struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// Len returns the length of s.
+func (s Span) Len() int64 {
+	return s.End - s.Start
+}
+
+// Offsets records the location of a part within the input to ReadMessageWithOptions.
+type Offsets struct {
+	// Header spans the part's header,
+	// including the blank line that ends it.
+	Header Span `json:"header"`
+
+	// Body spans the part's body.
+	Body Span `json:"body"`
+
+	// Fields has one element for each field in the part's header,
+	// spanning the field including any continuation lines and the final line ending.
+	// A field that did not come from the input
+	// (because it was added after parsing)
+	// has a span of {-1, -1}.
+	Fields []Span `json:"fields"`
+}
+
+// Offsets returns the location of p within the input from which it was parsed.
+// This is available only when parsing with ParseOptions.RecordOffsets;
+// otherwise Offsets returns nil.
+// It is also unavailable for parts within message/global parts that were transfer-encoded
+// (whose locations in the input are not well-defined).
+func (p *Part) Offsets() *Offsets {
+	if p.offsets == nil {
+		return nil
+	}
+	result := *p.offsets
+	result.Fields = nil
+	for _, f := range p.Fields {
+		span := f.span
+		if span.End == 0 {
+			span = Span{Start: -1, End: -1}
+		}
+		result.Fields = append(result.Fields, span)
+	}
+	return &result
+}
@@ -0,0 +1,79 @@
+package rmime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOffsets(t *testing.T) {
+	const inp = "From: foo\r\n" +
+		"Subject: a\r\n continued\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"preamble\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"hello\r\n" +
+		"--b\r\n" +
+		"Content-Type: message/rfc822\r\n" +
+		"\r\n" +
+		"Subject: inner\r\n" +
+		"\r\n" +
+		"inner body\r\n" +
+		"--b--\r\n"
+
+	m, _, err := ReadMessageWithOptions(strings.NewReader(inp), &ParseOptions{RecordOffsets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := func(s Span) string {
+		if s.Start < 0 || s.End > int64(len(inp)) || s.Start > s.End {
+			t.Fatalf("bad span %v", s)
+		}
+		return inp[s.Start:s.End]
+	}
+	check := func(what string, s Span, want string) {
+		t.Helper()
+		if got := text(s); got != want {
+			t.Errorf("%s: got %q, want %q", what, got, want)
+		}
+	}
+
+	off := (*Part)(m).Offsets()
+	if off == nil {
+		t.Fatal("no offsets")
+	}
+	check("top header", off.Header, "From: foo\r\nSubject: a\r\n continued\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n")
+	check("top field 2", off.Fields[1], "Subject: a\r\n continued\r\n")
+	check("top body", off.Body, inp[off.Header.End:])
+
+	parts := m.B.(*Multipart).Parts
+
+	off = parts[0].Offsets()
+	check("part 1 header", off.Header, "Content-Type: text/plain\r\n\r\n")
+	check("part 1 field 1", off.Fields[0], "Content-Type: text/plain\r\n")
+	check("part 1 body", off.Body, "hello\r\n")
+
+	off = parts[1].Offsets()
+	check("part 2 body", off.Body, "Subject: inner\r\n\r\ninner body\r\n")
+
+	inner := parts[1].B.(*Message)
+	off = (*Part)(inner).Offsets()
+	check("inner header", off.Header, "Subject: inner\r\n\r\n")
+	check("inner body", off.Body, "inner body\r\n")
+
+	inner.Fields = append(inner.Fields, &Field{N: "X-New", V: []string{" 1"}})
+	if got := (*Part)(inner).Offsets().Fields[1]; got != (Span{-1, -1}) {
+		t.Errorf("got span %v for new field, want {-1, -1}", got)
+	}
+
+	m, err = ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off := (*Part)(m).Offsets(); off != nil {
+		t.Errorf("got offsets %+v without RecordOffsets", off)
+	}
+}
@@ -0,0 +1,345 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ParseOptions controls the behavior of ReadMessageWithOptions and WalkWithOptions.
+// The zero value gives the same behavior as ReadMessage and Walk.
+type ParseOptions struct {
+	// Lenient causes the parser to recover from malformed input
+	// the way mail clients do,
+	// instead of failing.
+	// Each recovery is reported as a Warning.
+	//
+	// In lenient mode:
+	//   - A header line that is not a well-formed field
+	//     (no colon, an illegal field name,
+	//     or a continuation line with no field to continue)
+	//     ends the header;
+	//     that line and everything after it are treated as body.
+	//   - A header cut off by the end of the input is accepted as is.
+	//   - A multipart body that is cut off before its final boundary
+	//     is closed at the end of the input.
+	//   - A multipart part with no boundary parameter,
+	//     or in which no boundary line appears,
+	//     is read as if it were text/plain.
+	//   - A message/* part of an unknown or unsupported subtype
+	//     is read as if it were text/plain.
+	//   - Anything following the header fields
+	//     of a message/global-headers,
+	//     message/disposition-notification,
+	//     or message/feedback-report body
+	//     is discarded.
+	Lenient bool
+
+	// RecordOffsets causes the parser to record the location in the input
+	// of each part's header and body,
+	// and of each header field.
+	// See Part.Offsets.
+	RecordOffsets bool
+
+	// PreserveRaw causes the parser to keep the original bytes
+	// of each header field, header terminator, and multipart boundary line,
+	// and of each transfer-encoded message/* body,
+	// so that WriteTo reproduces unmodified input byte for byte,
+	// including its line endings.
+	// Whatever has been modified since parsing is written in the usual normalized form,
+	// and the rest is still written as it was.
+	PreserveRaw bool
+
+	// The remaining fields limit the resources that parsing may consume,
+	// as a defense against hostile input.
+	// Exceeding a limit produces a *LimitError.
+	// A zero value means no limit.
+
+	// MaxDepth limits how deeply parts may be nested
+	// (in multipart/* and message/* parts).
+	// The top-level message is at depth 1.
+	MaxDepth int
+
+	// MaxParts limits the total number of parts,
+	// including the top-level message.
+	MaxParts int
+
+	// MaxHeaderBytes limits the size of any single header.
+	MaxHeaderBytes int
+
+	// MaxFields limits the number of fields in any single header.
+	MaxFields int
+
+	// MaxBodyBytes limits the total size of all bodies,
+	// preambles, and postambles.
+	// These are measured before any transfer decoding,
+	// which never enlarges its input,
+	// so this also limits the total decoded size.
+	MaxBodyBytes int64
+}
+
+// LimitError is the error produced when parsing exceeds one of the limits in ParseOptions.
+type LimitError struct {
+	Limit string // The name of the ParseOptions field, e.g. "MaxDepth".
+	Value int64  // The value of that limit.
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("parse limit exceeded: %s = %d", e.Limit, e.Value)
+}
+
+// WarningKind identifies the kind of problem described by a Warning.
+type WarningKind string
+
+// Values for WarningKind.
+const (
+	WarnHeaderGarbage         WarningKind = "header-garbage"
+	WarnOrphanContinuation    WarningKind = "orphan-continuation"
+	WarnTruncatedHeader       WarningKind = "truncated-header"
+	WarnMissingBoundaryParam  WarningKind = "missing-boundary-param"
+	WarnNoBoundary            WarningKind = "no-boundary"
+	WarnEmptyMultipart        WarningKind = "empty-multipart"
+	WarnUnterminatedMultipart WarningKind = "unterminated-multipart"
+	WarnUnknownType           WarningKind = "unknown-type"
+	WarnTrailingData          WarningKind = "trailing-data"
+
+	// Address repairs (see ParseAddressListLenient).
+	WarnAddressSpecials          WarningKind = "address-specials"
+	WarnAddressAngleBrackets     WarningKind = "address-angle-brackets"
+	WarnAddressJunk              WarningKind = "address-junk"
+	WarnAddressSyntax            WarningKind = "address-syntax"
+	WarnAddress8bit              WarningKind = "address-8bit"
+	WarnAddressQuotedEncodedWord WarningKind = "address-quoted-encoded-word"
+)
+
+// Warning describes a problem that the parser worked around in lenient mode.
+type Warning struct {
+	Kind   WarningKind `json:"kind"`
+	Detail string      `json:"detail"`
+}
+
+func (w *Warning) String() string {
+	return string(w.Kind) + ": " + w.Detail
+}
+
+// parser holds the state of a single parse:
+// the options in effect,
+// the warnings collected so far,
+// and the resources consumed so far.
+type parser struct {
+	opts     ParseOptions
+	warnings []*Warning
+
+	depth     int
+	parts     int
+	bodyBytes int64
+}
+
+func newParser(opts *ParseOptions) *parser {
+	ps := new(parser)
+	if opts != nil {
+		ps.opts = *opts
+	}
+	return ps
+}
+
+func (ps *parser) warn(kind WarningKind, format string, args ...interface{}) {
+	ps.warnings = append(ps.warnings, &Warning{Kind: kind, Detail: fmt.Sprintf(format, args...)})
+}
+
+// enterPart is called on starting to parse a part.
+// It must be paired with a call to leavePart.
+func (ps *parser) enterPart() error {
+	ps.depth++
+	ps.parts++
+	if ps.opts.MaxDepth > 0 && ps.depth > ps.opts.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Value: int64(ps.opts.MaxDepth)}
+	}
+	if ps.opts.MaxParts > 0 && ps.parts > ps.opts.MaxParts {
+		return &LimitError{Limit: "MaxParts", Value: int64(ps.opts.MaxParts)}
+	}
+	return nil
+}
+
+func (ps *parser) leavePart() {
+	ps.depth--
+}
+
+// bodyReader wraps r, counting the bytes read from it against MaxBodyBytes.
+func (ps *parser) bodyReader(r io.Reader) io.Reader {
+	if ps.opts.MaxBodyBytes <= 0 {
+		return r
+	}
+	return &bodyLimitReader{ps: ps, r: r}
+}
+
+type bodyLimitReader struct {
+	ps *parser
+	r  io.Reader
+}
+
+func (br *bodyLimitReader) Read(buf []byte) (int, error) {
+	ps := br.ps
+	remaining := ps.opts.MaxBodyBytes - ps.bodyBytes
+	if remaining < int64(len(buf)) {
+		// Read one byte more than allowed, to detect exceeding the limit.
+		buf = buf[:remaining+1]
+	}
+	n, err := br.r.Read(buf)
+	ps.bodyBytes += int64(n)
+	if ps.bodyBytes > ps.opts.MaxBodyBytes {
+		return n, &LimitError{Limit: "MaxBodyBytes", Value: ps.opts.MaxBodyBytes}
+	}
+	return n, err
+}
+
+// fieldsReader is like bodyReader
+// but for bodies that are read as header fields
+// (message/delivery-status and the like),
+// which need a Reader.
+func (ps *parser) fieldsReader(r Reader) Reader {
+	if ps.opts.MaxBodyBytes <= 0 {
+		return r
+	}
+	return &bodyLimitByteReader{bodyLimitReader: bodyLimitReader{ps: ps, r: r}, br: r}
+}
+
+type bodyLimitByteReader struct {
+	bodyLimitReader
+	br Reader
+}
+
+func (br *bodyLimitByteReader) ReadByte() (byte, error) {
+	ps := br.ps
+	if ps.bodyBytes >= ps.opts.MaxBodyBytes {
+		if _, err := br.br.ReadByte(); err != nil {
+			return 0, err
+		}
+		return 0, &LimitError{Limit: "MaxBodyBytes", Value: ps.opts.MaxBodyBytes}
+	}
+	c, err := br.br.ReadByte()
+	if err == nil {
+		ps.bodyBytes++
+	}
+	return c, err
+}
+
+func (br *bodyLimitByteReader) offset() int64 {
+	if p, ok := br.br.(positioner); ok {
+		return p.offset()
+	}
+	return -1
+}
+
+// positioner is implemented by the readers that know their offset within the parser's input.
+type positioner interface {
+	// offset returns the number of bytes of the input preceding the next byte to be read,
+	// or -1 if that is unknown.
+	offset() int64
+}
+
+// pos returns the offset of r within the input,
+// or -1 if offsets are not being recorded or r's offset is unknown.
+func (ps *parser) pos(r interface{}) int64 {
+	if !ps.opts.RecordOffsets {
+		return -1
+	}
+	if p, ok := r.(positioner); ok {
+		return p.offset()
+	}
+	return -1
+}
+
+// offsetReader is a Reader that counts the bytes read from it.
+type offsetReader struct {
+	r Reader
+	n int64
+}
+
+func (or *offsetReader) Read(buf []byte) (int, error) {
+	n, err := or.r.Read(buf)
+	or.n += int64(n)
+	return n, err
+}
+
+func (or *offsetReader) ReadByte() (byte, error) {
+	c, err := or.r.ReadByte()
+	if err == nil {
+		or.n++
+	}
+	return c, err
+}
+
+func (or *offsetReader) offset() int64 {
+	return or.n
+}
+
+// prefixReader is a Reader that produces the bytes of prefix
+// before those of r.
+type prefixReader struct {
+	prefix []byte
+	r      Reader
+}
+
+func (pr *prefixReader) Read(buf []byte) (int, error) {
+	if len(pr.prefix) > 0 {
+		n := copy(buf, pr.prefix)
+		pr.prefix = pr.prefix[n:]
+		return n, nil
+	}
+	return pr.r.Read(buf)
+}
+
+func (pr *prefixReader) ReadByte() (byte, error) {
+	if len(pr.prefix) > 0 {
+		c := pr.prefix[0]
+		pr.prefix = pr.prefix[1:]
+		return c, nil
+	}
+	return pr.r.ReadByte()
+}
+
+// teeReader is a Reader that copies the bytes read from r to buf.
+type teeReader struct {
+	r   Reader
+	buf *bytes.Buffer
+}
+
+func (tr *teeReader) Read(buf []byte) (int, error) {
+	n, err := tr.r.Read(buf)
+	tr.buf.Write(buf[:n])
+	return n, err
+}
+
+func (tr *teeReader) ReadByte() (byte, error) {
+	c, err := tr.r.ReadByte()
+	if err == nil {
+		tr.buf.WriteByte(c)
+	}
+	return c, err
+}
+
+// byteReader adapts an io.ByteReader to the Reader interface.
+type byteReader struct {
+	io.ByteReader
+}
+
+func (br byteReader) Read(buf []byte) (int, error) {
+	for i := range buf {
+		c, err := br.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		buf[i] = c
+	}
+	return len(buf), nil
+}
+
+func (pr *prefixReader) offset() int64 {
+	if p, ok := pr.r.(positioner); ok {
+		if off := p.offset(); off >= 0 {
+			return off - int64(len(pr.prefix))
+		}
+	}
+	return -1
+}
@@ -0,0 +1,251 @@
+package rmime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLenient(t *testing.T) {
+	cases := []struct {
+		inp        string
+		wantFields []string
+		wantBody   string // for leaf parts
+		wantParts  int    // for multipart parts
+		wantWarn   []WarningKind
+		strictOK   bool // whether strict mode accepts the input too
+	}{{
+		inp:        "From: a\nthis is garbage\nTo: b\n\nbody\n",
+		wantFields: []string{"From"},
+		wantBody:   "this is garbage\nTo: b\n\nbody\n",
+		wantWarn:   []WarningKind{WarnHeaderGarbage},
+	}, {
+		inp:        "From: a\nbad name: x\n\nbody\n",
+		wantFields: []string{"From"},
+		wantBody:   "bad name: x\n\nbody\n",
+		wantWarn:   []WarningKind{WarnHeaderGarbage},
+		strictOK:   true,
+	}, {
+		inp:      " leading\nFrom: a\n\nbody\n",
+		wantBody: " leading\nFrom: a\n\nbody\n",
+		wantWarn: []WarningKind{WarnOrphanContinuation},
+	}, {
+		inp:        "From: a\nTo: b",
+		wantFields: []string{"From", "To"},
+		wantWarn:   []WarningKind{WarnTruncatedHeader},
+	}, {
+		inp:        "Content-Type: multipart/mixed\n\nhello\n",
+		wantFields: []string{"Content-Type"},
+		wantBody:   "hello\n",
+		wantWarn:   []WarningKind{WarnMissingBoundaryParam},
+	}, {
+		inp:        "Content-Type: multipart/mixed; boundary=b\n\nhello\n",
+		wantFields: []string{"Content-Type"},
+		wantBody:   "hello\n",
+		wantWarn:   []WarningKind{WarnNoBoundary},
+	}, {
+		inp:        "Content-Type: multipart/mixed; boundary=b\n\n--b\n\none\n--b\n\ntwo\n",
+		wantFields: []string{"Content-Type"},
+		wantParts:  2,
+		wantWarn:   []WarningKind{WarnUnterminatedMultipart},
+	}, {
+		inp:        "Content-Type: multipart/mixed; boundary=b\n\n--b\nContent-Type: text/plain",
+		wantFields: []string{"Content-Type"},
+		wantParts:  1,
+		wantWarn:   []WarningKind{WarnTruncatedHeader, WarnUnterminatedMultipart},
+	}, {
+		inp:        "Content-Type: multipart/mixed; boundary=b\n\n--b--\n",
+		wantFields: []string{"Content-Type"},
+		wantWarn:   []WarningKind{WarnEmptyMultipart},
+	}, {
+		inp:        "Content-Type: message/x-unknown\n\nhello\n",
+		wantFields: []string{"Content-Type"},
+		wantBody:   "hello\n",
+		wantWarn:   []WarningKind{WarnUnknownType},
+	}, {
+		inp:        "Content-Type: multipart/mixed; boundary=x\n\n--x\n--x--\n",
+		wantFields: []string{"Content-Type"},
+		wantParts:  1,
+		wantWarn:   []WarningKind{WarnTruncatedHeader},
+	}, {
+		inp:      "",
+		wantWarn: []WarningKind{WarnTruncatedHeader},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			if _, err := ReadMessage(strings.NewReader(tc.inp)); err == nil && !tc.strictOK {
+				t.Error("got no error in strict mode")
+			}
+
+			m, warnings, err := ReadMessageWithOptions(strings.NewReader(tc.inp), &ParseOptions{Lenient: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotFields []string
+			for _, f := range m.Fields {
+				gotFields = append(gotFields, f.Name())
+			}
+			if !reflect.DeepEqual(gotFields, tc.wantFields) {
+				t.Errorf("got fields %v, want %v", gotFields, tc.wantFields)
+			}
+
+			switch body := m.B.(type) {
+			case string:
+				if body != tc.wantBody {
+					t.Errorf("got body %q, want %q", body, tc.wantBody)
+				}
+			case *Multipart:
+				if len(body.Parts) != tc.wantParts {
+					t.Errorf("got %d parts, want %d", len(body.Parts), tc.wantParts)
+				}
+			default:
+				t.Errorf("got body of type %T", body)
+			}
+
+			var gotWarn []WarningKind
+			for _, w := range warnings {
+				gotWarn = append(gotWarn, w.Kind)
+			}
+			if !reflect.DeepEqual(gotWarn, tc.wantWarn) {
+				t.Errorf("got warnings %v, want %v", warnings, tc.wantWarn)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	nested := "Content-Type: message/rfc822\n\n" + "Content-Type: message/rfc822\n\n" + "Subject: innermost\n\nhello\n"
+	multi := "Content-Type: multipart/mixed; boundary=b\n\n--b\n\none\n--b\n\ntwo\n--b\n\nthree\n--b--\n"
+	dsnBody := "Reporting-MTA: dns; mx.example.com\n" + strings.Repeat("\nFinal-Recipient: rfc822; a@example.com\nAction: failed\n", 100)
+	dsn := "Content-Type: message/delivery-status\n\n" + dsnBody
+	mdnBody := "Final-Recipient: rfc822; a@example.com\nDisposition: manual-action/MDN-sent-manually; displayed\n"
+	mdn := "Content-Type: message/disposition-notification\n\n" + mdnBody
+
+	cases := []struct {
+		inp       string
+		opts      ParseOptions
+		wantLimit string
+	}{
+		{inp: nested, opts: ParseOptions{MaxDepth: 3}},
+		{inp: nested, opts: ParseOptions{MaxDepth: 2}, wantLimit: "MaxDepth"},
+		{inp: multi, opts: ParseOptions{MaxParts: 4}},
+		{inp: multi, opts: ParseOptions{MaxParts: 3}, wantLimit: "MaxParts"},
+		{inp: multi, opts: ParseOptions{MaxHeaderBytes: 43}},
+		{inp: multi, opts: ParseOptions{MaxHeaderBytes: 42}, wantLimit: "MaxHeaderBytes"},
+		{inp: "A: 1\nB: 2\nC: 3\n\n", opts: ParseOptions{MaxFields: 3}},
+		{inp: "A: 1\nB: 2\nC: 3\n\n", opts: ParseOptions{MaxFields: 2}, wantLimit: "MaxFields"},
+		{inp: multi, opts: ParseOptions{MaxBodyBytes: 14}},
+		{inp: multi, opts: ParseOptions{MaxBodyBytes: 13}, wantLimit: "MaxBodyBytes"},
+		{inp: dsn, opts: ParseOptions{MaxBodyBytes: int64(len(dsnBody))}},
+		{inp: dsn, opts: ParseOptions{MaxBodyBytes: int64(len(dsnBody)) - 1}, wantLimit: "MaxBodyBytes"},
+		{inp: dsn, opts: ParseOptions{MaxBodyBytes: 100}, wantLimit: "MaxBodyBytes"},
+		{inp: mdn, opts: ParseOptions{MaxBodyBytes: int64(len(mdnBody))}},
+		{inp: mdn, opts: ParseOptions{MaxBodyBytes: int64(len(mdnBody)) - 1}, wantLimit: "MaxBodyBytes"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			check := func(err error) {
+				t.Helper()
+				if tc.wantLimit == "" {
+					if err != nil {
+						t.Errorf("got error %v", err)
+					}
+					return
+				}
+				var lerr *LimitError
+				if !errors.As(err, &lerr) {
+					t.Errorf("got error %v, want a LimitError", err)
+					return
+				}
+				if lerr.Limit != tc.wantLimit {
+					t.Errorf("got limit %s, want %s", lerr.Limit, tc.wantLimit)
+				}
+			}
+
+			_, _, err := ReadMessageWithOptions(strings.NewReader(tc.inp), &tc.opts)
+			check(err)
+
+			_, err = WalkWithOptions(strings.NewReader(tc.inp), &tc.opts, func(*Header, io.Reader) error { return nil })
+			check(err)
+		})
+	}
+}
+
+func TestPreserveRaw(t *testing.T) {
+	cases := []struct {
+		inp     string
+		lenient bool
+	}{{
+		inp: "From: a\r\nSubject:no space\r\nX-Folded: one\r\n\ttwo\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"preamble\r\n--b  \r\n\r\none\r\n--b\t\r\nContent-Type: text/plain\r\n\r\ntwo\r\n--b-- \r\npostamble\r\n",
+	}, {
+		inp: "Content-Type: message/global\nContent-Transfer-Encoding: base64\n\n" +
+			"RnJvbTogYQ0KU3ViamVj\ndDogaGkNCg0KaGVsbG8N\nCg==\n",
+	}, {
+		inp: "Content-Type: message/delivery-status\n\nReporting-MTA: dns; a.example\n\nFinal-Recipient: rfc822; b@example\nAction: failed\n",
+	}, {
+		inp:     "From: a\nthis is garbage\n\nbody\n",
+		lenient: true,
+	}, {
+		inp:     "Content-Type: multipart/mixed; boundary=b\n\n--b\n\none",
+		lenient: true,
+	}, {
+		inp: "Content-Type: multipart/mixed; boundary=b\n\n--b\n\none\n--b--",
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			m, _, err := ReadMessageWithOptions(strings.NewReader(tc.inp), &ParseOptions{PreserveRaw: true, Lenient: tc.lenient})
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := new(strings.Builder)
+			if _, err := m.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.inp {
+				t.Errorf("got %q, want %q", got, tc.inp)
+			}
+		})
+	}
+
+	t.Run("modified", func(t *testing.T) {
+		m, _, err := ReadMessageWithOptions(strings.NewReader(cases[0].inp), &ParseOptions{PreserveRaw: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Fields[1].V = []string{" changed"}
+		m.B.(*Multipart).Parts[1].B = "TWO\r\n"
+		buf := new(strings.Builder)
+		if _, err := m.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		want := "From: a\r\nSubject: changed\nX-Folded: one\r\n\ttwo\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"preamble\r\n--b  \r\n\r\none\r\n--b\t\r\nContent-Type: text/plain\r\n\r\nTWO\r\n--b-- \r\npostamble\r\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		m, _, err = ReadMessageWithOptions(strings.NewReader(cases[1].inp), &ParseOptions{PreserveRaw: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner := m.B.(*Message)
+		inner.Fields[1].V = []string{" bye"}
+		buf.Reset()
+		if _, err := m.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		want = "Content-Type: message/global\nContent-Transfer-Encoding: base64\n\n" +
+			"RnJvbTogYQ0KU3ViamVjdDogYnllCg0KaGVsbG8NCg==\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
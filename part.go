@@ -2,6 +2,7 @@ package rmime
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -15,37 +16,88 @@ import (
 type Part struct {
 	*Header
 	B interface{} `json:"body"`
+
+	offsets *Offsets // Location in the input, if recorded (see ParseOptions.RecordOffsets).
+	section string   // IMAP section specifier (see SectionSpec).
+
+	// The transfer-encoded body and a hash of its decoded form (see bodySum),
+	// if recorded (see ParseOptions.PreserveRaw).
+	rawBody, rawSum []byte
 }
 
 // ReadPart reads a message part from r after having read and parsed a
 // header for it.
 func ReadPart(r Reader, header *Header) (*Part, error) {
+	return newParser(nil).readPart(r, header)
+}
+
+func (ps *parser) readPart(r Reader, header *Header) (*Part, error) {
+	defer ps.leavePart()
+	if err := ps.enterPart(); err != nil {
+		return nil, err
+	}
+
 	defaultType := "text/plain"
 	if header != nil && header.Type() == "multipart/digest" {
 		defaultType = "message/rfc822"
 	}
-	innerHeader, err := ReadHeader(r, defaultType)
+	headerStart := ps.pos(r)
+	innerHeader, r, err := ps.readHeader(r, defaultType)
+	if errors.Is(err, io.EOF) && ps.opts.Lenient {
+		ps.warn(WarnTruncatedHeader, "empty part")
+		innerHeader, err = &Header{DefaultType: defaultType}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	body, err := ReadBody(r, innerHeader)
+	bodyStart := ps.pos(r)
+	var (
+		br  = r
+		raw *bytes.Buffer
+	)
+	if ps.opts.PreserveRaw && innerHeader.MajorType() == "message" {
+		switch innerHeader.Encoding() {
+		case "base64", "quoted-printable":
+			// Re-encoding would not reproduce the original bytes.
+			raw = new(bytes.Buffer)
+			br = &teeReader{r: r, buf: raw}
+		}
+	}
+	body, err := ps.readBody(br, innerHeader)
 	if err != nil {
 		return nil, err
 	}
-	return &Part{Header: innerHeader, B: body}, nil
+	result := &Part{Header: innerHeader, B: body}
+	if raw != nil {
+		if _, err := io.Copy(io.Discard, br); err != nil {
+			return nil, err
+		}
+		result.rawBody = raw.Bytes()
+		if result.rawSum, err = result.bodySum(); err != nil {
+			result.rawBody = nil
+		}
+	}
+	if headerStart >= 0 && bodyStart >= 0 {
+		result.offsets = &Offsets{
+			Header: Span{Start: headerStart, End: bodyStart},
+			Body:   Span{Start: bodyStart, End: ps.pos(r)},
+		}
+	}
+	return result, nil
 }
 
 // Raw produces a reader over the body of the part.
 // It does no decoding.
 //
-// It is an error to call Raw on non-leaf parts (multipart/*, message/*).
+// It is an error to call Raw on non-leaf parts (multipart/*, message/*),
+// except for those read as text in lenient mode.
 func (p *Part) Raw() (io.Reader, error) {
-	switch p.MajorType() {
-	case "multipart", "message":
-		return nil, fmt.Errorf("cannot call Body() on type %s", p.Type())
-	}
 	body, ok := p.B.(string)
 	if !ok {
+		switch p.MajorType() {
+		case "multipart", "message":
+			return nil, fmt.Errorf("cannot call Body() on type %s", p.Type())
+		}
 		return nil, fmt.Errorf("Content-Type is %s but body object is %T (want string)", p.Type(), p.B)
 	}
 
@@ -60,28 +112,32 @@ func (p *Part) Raw() (io.Reader, error) {
 // It is an error to call Body on non-leaf parts
 // (multipart/*, message/*).
 func (p *Part) Body() (io.Reader, error) {
-	var (
-		r   io.Reader
-		err error
-	)
-	if r, err = p.Raw(); err != nil {
+	r, err := p.Raw()
+	if err != nil {
 		return nil, errors.Wrap(err, "getting raw body")
 	}
+	return DecodeBody(r, p.Header)
+}
 
-	switch p.Encoding() {
+// DecodeBody produces a reader over the decoded form of the raw body in r,
+// interpreted according to header.
+// It does the same decoding as Part.Body,
+// and is useful with the body readers supplied by Walk.
+func DecodeBody(r io.Reader, header *Header) (io.Reader, error) {
+	switch header.Encoding() {
 	case "quoted-printable":
 		r = quotedprintable.NewReader(r)
 	case "base64":
 		r = base64.NewDecoder(base64.StdEncoding, r)
 	}
-	if p.MajorType() == "text" {
+	if header.MajorType() == "text" {
 		var err error
-		r, err = charsetReader(p.Charset(), r)
+		r, err = charsetReader(header.Charset(), r)
 		if err != nil {
 			return nil, err
 		}
-		if p.MinorType() == "plain" {
-			params := p.Params()
+		if header.MinorType() == "plain" {
+			params := header.Params()
 			if params != nil {
 				format := strings.ToLower(strings.TrimSpace(params["format"]))
 				delsp := strings.ToLower(strings.TrimSpace(params["delsp"]))
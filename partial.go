@@ -0,0 +1,307 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bobg/errors"
+)
+
+// Partial parses the parameters of a message/partial Content-Type field:
+// the id shared by all the fragments of a message,
+// the number of this fragment (starting at 1),
+// and the total number of fragments
+// (which may be 0, meaning unknown, in all but the last fragment).
+// The final return value is false if h does not describe a well-formed message/partial.
+func (h Header) Partial() (id string, number, total int, ok bool) {
+	if h.Type() != "message/partial" {
+		return "", 0, 0, false
+	}
+	params := h.Params()
+	id = params["id"]
+	if id == "" {
+		return "", 0, 0, false
+	}
+	number, err := strconv.Atoi(strings.TrimSpace(params["number"]))
+	if err != nil || number < 1 {
+		return "", 0, 0, false
+	}
+	if t, ok := params["total"]; ok {
+		total, err = strconv.Atoi(strings.TrimSpace(t))
+		if err != nil || total < number {
+			return "", 0, 0, false
+		}
+	}
+	return id, number, total, true
+}
+
+// PartialCollector collects the fragments of message/partial messages,
+// grouped by id,
+// and reassembles each message once all its fragments are present.
+// The zero value is ready to use.
+type PartialCollector struct {
+	// Options, if not nil, directs the parsing of reassembled messages.
+	Options *ParseOptions
+
+	sets map[string]*partialSet
+}
+
+type partialSet struct {
+	total int
+	frags map[int]*Message
+}
+
+// Add adds the fragment m to the collection.
+// If it completes a message,
+// that message is reassembled (see Reassemble),
+// removed from the collection,
+// and returned.
+// Otherwise Add returns nil.
+//
+// If reassembly fails,
+// the fragments remain in the collection,
+// so that it can be retried (see Retry) or abandoned (see Discard).
+func (c *PartialCollector) Add(m *Message) (*Message, []*Warning, error) {
+	id, number, total, ok := m.Partial()
+	if !ok {
+		return nil, nil, fmt.Errorf("not a well-formed message/partial")
+	}
+	if c.sets == nil {
+		c.sets = make(map[string]*partialSet)
+	}
+	set := c.sets[id]
+	if set == nil {
+		set = &partialSet{frags: make(map[int]*Message)}
+		c.sets[id] = set
+	}
+	if total > 0 {
+		if set.total > 0 && set.total != total {
+			return nil, nil, fmt.Errorf("message/partial %s: conflicting totals %d and %d", id, set.total, total)
+		}
+		set.total = total
+	}
+	if _, ok := set.frags[number]; ok {
+		return nil, nil, fmt.Errorf("message/partial %s: duplicate fragment %d", id, number)
+	}
+	set.frags[number] = m
+
+	if set.total == 0 || len(set.frags) < set.total {
+		return nil, nil, nil
+	}
+	return c.reassemble(id, set)
+}
+
+// Retry retries the reassembly of the message with the given id
+// after a failure in Add,
+// e.g. with different Options.
+func (c *PartialCollector) Retry(id string) (*Message, []*Warning, error) {
+	set := c.sets[id]
+	if set == nil || set.total == 0 || len(set.frags) < set.total {
+		return nil, nil, fmt.Errorf("message/partial %s: fragments incomplete", id)
+	}
+	return c.reassemble(id, set)
+}
+
+// Discard removes the fragments of the message with the given id
+// from the collection.
+func (c *PartialCollector) Discard(id string) {
+	delete(c.sets, id)
+}
+
+func (c *PartialCollector) reassemble(id string, set *partialSet) (*Message, []*Warning, error) {
+	frags := make([]*Message, 0, set.total)
+	for _, f := range set.frags {
+		frags = append(frags, f)
+	}
+	result, warnings, err := Reassemble(frags, c.Options)
+	if err != nil {
+		return nil, warnings, err
+	}
+	delete(c.sets, id)
+	return result, warnings, nil
+}
+
+// Pending returns the ids of the messages for which some,
+// but not all,
+// fragments have been collected.
+func (c *PartialCollector) Pending() []string {
+	var result []string
+	for id := range c.sets {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Reassemble combines a complete set of message/partial fragments,
+// in any order,
+// into the message they encapsulate,
+// parsing it as directed by opts (which may be nil).
+//
+// The header of the result is produced per RFC2046 section 5.2.2.1:
+// the fields of the first fragment's header,
+// except for Content-*, Subject, Message-ID, Encrypted, and MIME-Version,
+// followed by those fields (only) from the encapsulated message's header.
+// The headers of the other fragments are discarded.
+func Reassemble(frags []*Message, opts *ParseOptions) (*Message, []*Warning, error) {
+	if len(frags) == 0 {
+		return nil, nil, fmt.Errorf("no fragments")
+	}
+
+	type numbered struct {
+		number int
+		m      *Message
+	}
+	var (
+		sorted  []numbered
+		firstID string
+		total   int
+	)
+	for i, f := range frags {
+		id, number, t, ok := f.Partial()
+		if !ok {
+			return nil, nil, fmt.Errorf("fragment %d is not a well-formed message/partial", i+1)
+		}
+		if i == 0 {
+			firstID = id
+		} else if id != firstID {
+			return nil, nil, fmt.Errorf("fragments have different ids %s and %s", firstID, id)
+		}
+		if t > 0 {
+			total = t
+		}
+		sorted = append(sorted, numbered{number: number, m: f})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].number < sorted[j].number })
+	if total != len(sorted) {
+		return nil, nil, fmt.Errorf("message/partial %s: have %d fragments, want %d", firstID, len(sorted), total)
+	}
+
+	buf := new(bytes.Buffer)
+	for i, n := range sorted {
+		if n.number != i+1 {
+			return nil, nil, fmt.Errorf("message/partial %s: missing fragment %d", firstID, i+1)
+		}
+		body, ok := n.m.B.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("message/partial %s: fragment %d has body of type %T (want string)", firstID, i+1, n.m.B)
+		}
+		buf.WriteString(body)
+	}
+
+	inner, warnings, err := ReadMessageWithOptions(bytes.NewReader(buf.Bytes()), opts)
+	if err != nil {
+		return nil, warnings, errors.Wrapf(err, "parsing reassembled message/partial %s", firstID)
+	}
+
+	h := &Header{DefaultType: inner.DefaultType}
+	for _, f := range sorted[0].m.Fields {
+		if !isEncapsulatedField(f.Name()) {
+			h.Fields = append(h.Fields, copyField(f))
+		}
+	}
+	for _, f := range inner.Fields {
+		if isEncapsulatedField(f.Name()) {
+			h.Fields = append(h.Fields, f)
+		}
+	}
+	return &Message{Header: h, B: inner.B}, warnings, nil
+}
+
+// Fragment splits m into message/partial fragments with the given id,
+// for transmission through a channel that limits message size.
+// The body of each fragment is at most maxSize bytes.
+// Splits occur only at line boundaries,
+// so it is an error if m contains any line longer than maxSize.
+//
+// Per RFC2046,
+// the Content-*, Subject, Message-ID, Encrypted, and MIME-Version fields of m
+// are encapsulated in the first fragment,
+// and the remaining fields are copied to the header of every fragment,
+// along with Subject and the message/partial Content-Type.
+func (m *Message) Fragment(id string, maxSize int) ([]*Message, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("bad maximum fragment size %d", maxSize)
+	}
+
+	var (
+		outer   []*Field
+		inner   = &Header{DefaultType: m.DefaultType}
+		subject *Field
+	)
+	for _, f := range m.Fields {
+		name := f.Name()
+		if isEncapsulatedField(name) {
+			inner.Fields = append(inner.Fields, f)
+			if name == "Subject" {
+				subject = f
+			}
+		} else {
+			outer = append(outer, f)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := (&Part{Header: inner, B: m.B}).WriteTo(buf); err != nil {
+		return nil, errors.Wrap(err, "rendering message")
+	}
+
+	var chunks []string
+	for rest := buf.String(); len(rest) > 0; {
+		n := len(rest)
+		if n > maxSize {
+			n = strings.LastIndexByte(rest[:maxSize], '\n') + 1
+			if n == 0 {
+				return nil, fmt.Errorf("line too long for fragment size %d", maxSize)
+			}
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+
+	var result []*Message
+	for i, chunk := range chunks {
+		h := &Header{DefaultType: "text/plain"}
+		for _, f := range outer {
+			h.Fields = append(h.Fields, copyField(f))
+		}
+		if subject != nil {
+			h.Fields = append(h.Fields, copyField(subject))
+		}
+		ct := mime.FormatMediaType("message/partial", map[string]string{
+			"id":     id,
+			"number": strconv.Itoa(i + 1),
+			"total":  strconv.Itoa(len(chunks)),
+		})
+		h.Fields = append(h.Fields,
+			&Field{N: "MIME-Version", V: []string{" 1.0"}},
+			&Field{N: "Content-Type", V: []string{" " + ct}},
+		)
+		result = append(result, &Message{Header: h, B: chunk})
+	}
+	return result, nil
+}
+
+// isEncapsulatedField tells whether the field with the given canonical name
+// belongs to the encapsulated message
+// (rather than the enclosing fragment)
+// of a message/partial,
+// per RFC2046 section 5.2.2.1.
+func isEncapsulatedField(name string) bool {
+	if strings.HasPrefix(name, "Content-") {
+		return true
+	}
+	switch name {
+	case "Subject", "Message-ID", "Encrypted", "MIME-Version":
+		return true
+	}
+	return false
+}
+
+func copyField(f *Field) *Field {
+	return &Field{N: f.N, V: append([]string(nil), f.V...)}
+}
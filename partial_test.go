@@ -0,0 +1,220 @@
+package rmime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFragmentReassemble(t *testing.T) {
+	const inp = `From: foo
+To: bar
+Subject: big
+Message-Id: <a@b>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="xyz"
+
+--xyz
+Content-Type: text/plain
+
+line one
+line two
+line three
+--xyz
+Content-Type: text/plain
+
+line four
+line five
+--xyz--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frags, err := m.Fragment("frag@example", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frags) < 2 {
+		t.Fatalf("got %d fragments, want more", len(frags))
+	}
+
+	// Send the fragments through a render/parse cycle,
+	// in order, in reverse order, and in a shuffled (but repeatable) order.
+	var (
+		inOrder  = make([]int, len(frags))
+		reversed = make([]int, len(frags))
+	)
+	for i := range frags {
+		inOrder[i] = i
+		reversed[i] = len(frags) - 1 - i
+	}
+	orders := [][]int{inOrder, reversed, rand.New(rand.NewSource(1)).Perm(len(frags))}
+
+	for i, order := range orders {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			var (
+				c      PartialCollector
+				result *Message
+			)
+			for j, k := range order {
+				f := frags[k]
+				if b, ok := f.B.(string); !ok || len(b) > 60 {
+					t.Errorf("fragment body %q too long", f.B)
+				}
+
+				buf := new(bytes.Buffer)
+				if _, err := f.WriteTo(buf); err != nil {
+					t.Fatal(err)
+				}
+				f, err := ReadMessage(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := f.Subject(); got != "big" {
+					t.Errorf("got fragment subject %q, want big", got)
+				}
+				got, _, err := c.Add(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if j < len(order)-1 {
+					if got != nil {
+						t.Fatalf("got reassembled message after %d of %d fragments", j+1, len(order))
+					}
+					continue
+				}
+				result = got
+			}
+			if result == nil {
+				t.Fatal("no reassembled message")
+			}
+			if pending := c.Pending(); len(pending) > 0 {
+				t.Errorf("got pending %v, want none", pending)
+			}
+
+			buf := new(bytes.Buffer)
+			if _, err := result.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			// The encapsulated fields happen to come last in inp already,
+			// so the reassembled header has the original order.
+			if got := buf.String(); got != inp {
+				t.Errorf("got:\n%s\nwant:\n%s", got, inp)
+			}
+		})
+	}
+}
+
+func TestReassembleMerge(t *testing.T) {
+	const (
+		frag1 = `From: outer@example
+Subject: outer subject
+X-Outer: 1
+Content-Type: message/partial; id="abc"; number=1; total=2
+
+Subject: inner subject
+Message-Id: <inner@example>
+X-Inner: dropped
+Content-Type: text/plain
+
+first half
+`
+		frag2 = `From: other@example
+X-Outer: 2
+Content-Type: message/partial; id="abc"; number=2; total=2
+
+second half
+`
+		want = `From: outer@example
+X-Outer: 1
+Subject: inner subject
+Message-Id: <inner@example>
+Content-Type: text/plain
+
+first half
+second half
+`
+	)
+
+	var frags []*Message
+	for _, inp := range []string{frag2, frag1} {
+		m, err := ReadMessage(strings.NewReader(inp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frags = append(frags, m)
+	}
+	m, _, err := Reassemble(frags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, _, err := Reassemble(frags[:1], nil); err == nil {
+		t.Error("got no error reassembling incomplete fragments")
+	}
+}
+
+func TestPartialCollectorFailure(t *testing.T) {
+	const (
+		frag1 = `Content-Type: message/partial; id="abc"; number=1; total=2
+
+Subject: inner subject
+X-Inner: 1
+`
+		frag2 = `Content-Type: message/partial; id="abc"; number=2; total=2
+
+X-Inner: 2
+
+body
+`
+	)
+
+	c := PartialCollector{Options: &ParseOptions{MaxFields: 2}}
+	for i, inp := range []string{frag1, frag2} {
+		m, err := ReadMessage(strings.NewReader(inp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := c.Add(m)
+		if i == 0 {
+			if err != nil || got != nil {
+				t.Fatalf("after first fragment, got %v, %v", got, err)
+			}
+			continue
+		}
+		var lerr *LimitError
+		if !errors.As(err, &lerr) {
+			t.Fatalf("got error %v, want a LimitError", err)
+		}
+	}
+	if pending := c.Pending(); len(pending) != 1 || pending[0] != "abc" {
+		t.Fatalf("got pending %v, want [abc]", pending)
+	}
+
+	c.Options = nil
+	m, _, err := c.Retry("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Subject(); got != "inner subject" {
+		t.Errorf("got subject %q, want inner subject", got)
+	}
+	if pending := c.Pending(); len(pending) != 0 {
+		t.Errorf("got pending %v, want none", pending)
+	}
+	if _, _, err := c.Retry("abc"); err == nil {
+		t.Error("got no error retrying a reassembled message")
+	}
+}
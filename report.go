@@ -0,0 +1,200 @@
+package rmime
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/bobg/errors"
+)
+
+// Report is the structure of a multipart/report part (RFC6522),
+// the format of delivery status notifications (bounces),
+// message disposition notifications (read receipts),
+// and abuse feedback reports,
+// among others.
+type Report struct {
+	// Type is the report-type parameter of the multipart/report part,
+	// canonicalized to lowercase,
+	// e.g. "delivery-status" or "disposition-notification".
+	Type string
+
+	// Part is the multipart/report part itself.
+	Part *Part
+
+	// Human is the human-readable part (normally the first part).
+	// It may be nil.
+	Human *Part
+
+	// Status is the machine-readable part (normally the second part),
+	// e.g. of type message/delivery-status.
+	// It may be nil.
+	Status *Part
+
+	// Original is the returned original message or its header
+	// (normally the third part),
+	// of type message/rfc822 or text/rfc822-headers
+	// (or message/global or message/global-headers).
+	// It may be nil.
+	Original *Part
+}
+
+// Report locates the multipart/report in m and identifies its components.
+// This is m itself if it is a multipart/report,
+// otherwise the first multipart/report found in a depth-first search of m's parts
+// (as when a bounce has been forwarded as an attachment).
+// It returns nil if there is none.
+func (m *Message) Report() *Report {
+	p := findReport((*Part)(m))
+	if p == nil {
+		return nil
+	}
+	mp, ok := p.B.(*Multipart)
+	if !ok {
+		return nil
+	}
+
+	r := &Report{
+		Type: strings.ToLower(strings.TrimSpace(p.Params()["report-type"])),
+		Part: p,
+	}
+
+	// Identify components by type where possible,
+	// falling back to the positions prescribed by RFC6522.
+	var rest []*Part
+	for _, sub := range mp.Parts {
+		switch {
+		case r.Status == nil && isReportStatusType(sub.Type(), r.Type):
+			r.Status = sub
+		case r.Original == nil && r.Status != nil && isReportOriginalType(sub.Type()):
+			r.Original = sub
+		default:
+			rest = append(rest, sub)
+		}
+	}
+	if len(rest) > 0 {
+		r.Human = rest[0]
+		rest = rest[1:]
+	}
+	if r.Status == nil && len(rest) > 0 {
+		r.Status = rest[0]
+		rest = rest[1:]
+	}
+	if r.Original == nil && len(rest) > 0 && isReportOriginalType(rest[0].Type()) {
+		r.Original = rest[0]
+	}
+	return r
+}
+
+func findReport(p *Part) *Part {
+	if p.Type() == "multipart/report" {
+		return p
+	}
+	switch body := p.B.(type) {
+	case *Multipart:
+		for _, sub := range body.Parts {
+			if found := findReport(sub); found != nil {
+				return found
+			}
+		}
+	case *Message:
+		return findReport((*Part)(body))
+	}
+	return nil
+}
+
+func isReportStatusType(typ, reportType string) bool {
+	if reportType != "" && typ == "message/"+reportType {
+		return true
+	}
+	switch typ {
+	case "message/delivery-status", "message/disposition-notification",
+		"message/global-delivery-status", "message/global-disposition-notification",
+		"message/feedback-report":
+		return true
+	}
+	return false
+}
+
+func isReportOriginalType(typ string) bool {
+	switch typ {
+	case "message/rfc822", "text/rfc822-headers",
+		"message/global", "message/global-headers":
+		return true
+	}
+	return false
+}
+
+// DeliveryStatus returns the parsed machine-readable part of r
+// if it is a message/delivery-status
+// (or message/global-delivery-status),
+// otherwise nil.
+func (r *Report) DeliveryStatus() *DeliveryStatus {
+	if r.Status == nil {
+		return nil
+	}
+	ds, _ := r.Status.B.(*DeliveryStatus)
+	return ds
+}
+
+// DispositionNotification returns the parsed machine-readable part of r
+// if it is a message/disposition-notification
+// (or message/global-disposition-notification),
+// otherwise nil.
+func (r *Report) DispositionNotification() *DispositionNotification {
+	if r.Status == nil {
+		return nil
+	}
+	dn, _ := r.Status.B.(*DispositionNotification)
+	return dn
+}
+
+// FeedbackReport returns the parsed machine-readable part of r
+// if it is a message/feedback-report,
+// otherwise nil.
+func (r *Report) FeedbackReport() *FeedbackReport {
+	if r.Status == nil {
+		return nil
+	}
+	fr, _ := r.Status.B.(*FeedbackReport)
+	return fr
+}
+
+// OriginalMessage returns the returned original message in r,
+// if it is present in full (as message/rfc822 or message/global),
+// otherwise nil.
+func (r *Report) OriginalMessage() *Message {
+	if r.Original == nil {
+		return nil
+	}
+	m, _ := r.Original.B.(*Message)
+	return m
+}
+
+// OriginalHeader returns the header of the returned original message in r,
+// whether it is present in full (as message/rfc822 or message/global)
+// or as just its header (text/rfc822-headers or message/global-headers).
+// It returns nil, nil if there is no returned original.
+func (r *Report) OriginalHeader() (*Header, error) {
+	if r.Original == nil {
+		return nil, nil
+	}
+	switch body := r.Original.B.(type) {
+	case *Message:
+		return body.Header, nil
+	case *Header:
+		return body, nil
+	}
+	body, err := r.Original.Body()
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding returned header")
+	}
+	h, _, err := newParser(nil).readFields(bufio.NewReader(body), "text/plain", true)
+	if errors.Is(err, io.EOF) {
+		return &Header{DefaultType: "text/plain"}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing returned header")
+	}
+	return h, nil
+}
@@ -0,0 +1,148 @@
+package rmime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	const bounce = `From: MAILER-DAEMON@example.com
+Content-Type: multipart/report; report-type=delivery-status;
+ boundary="b"
+
+--b
+Content-Type: text/plain
+
+Your message could not be delivered.
+--b
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+
+Final-Recipient: rfc822; nobody@example.org
+Action: failed
+Status: 5.1.1
+--b
+Content-Type: text/rfc822-headers
+
+From: me@example.com
+To: nobody@example.org
+Subject: hello
+--b--
+`
+
+	const forwarded = `From: someone@example.com
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: text/plain
+
+See the attached read receipt.
+--outer
+Content-Type: message/rfc822
+
+From: reader@example.org
+Content-Type: multipart/report; report-type=disposition-notification; boundary="b"
+
+--b
+Content-Type: text/plain
+
+Your message was displayed.
+--b
+Content-Type: message/disposition-notification
+
+Final-Recipient: rfc822; reader@example.org
+Disposition: automatic-action/MDN-sent-automatically; displayed
+--b
+Content-Type: message/rfc822
+
+From: me@example.com
+Subject: read this
+
+hi
+--b--
+--outer--
+`
+
+	t.Run("bounce", func(t *testing.T) {
+		m, err := ReadMessage(strings.NewReader(bounce))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := m.Report()
+		if r == nil {
+			t.Fatal("no report")
+		}
+		if r.Type != "delivery-status" {
+			t.Errorf("got type %s, want delivery-status", r.Type)
+		}
+		if r.Human == nil || r.Human.Type() != "text/plain" {
+			t.Errorf("got human-readable part %v", r.Human)
+		}
+		ds := r.DeliveryStatus()
+		if ds == nil {
+			t.Fatal("no delivery status")
+		}
+		if got := ds.PerRecipient()[0].FinalRecipient.Value; got != "nobody@example.org" {
+			t.Errorf("got final recipient %s", got)
+		}
+		if r.DispositionNotification() != nil {
+			t.Error("got a disposition notification")
+		}
+		if r.OriginalMessage() != nil {
+			t.Error("got an original message")
+		}
+		h, err := r.OriginalHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := h.Subject(); got != "hello" {
+			t.Errorf("got original subject %q, want hello", got)
+		}
+	})
+
+	t.Run("forwarded", func(t *testing.T) {
+		m, err := ReadMessage(strings.NewReader(forwarded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := m.Report()
+		if r == nil {
+			t.Fatal("no report")
+		}
+		if r.Type != "disposition-notification" {
+			t.Errorf("got type %s, want disposition-notification", r.Type)
+		}
+		dn := r.DispositionNotification()
+		if dn == nil {
+			t.Fatal("no disposition notification")
+		}
+		if got := dn.Notification().Disposition.Type; got != "displayed" {
+			t.Errorf("got disposition type %s, want displayed", got)
+		}
+		orig := r.OriginalMessage()
+		if orig == nil {
+			t.Fatal("no original message")
+		}
+		if got := orig.Subject(); got != "read this" {
+			t.Errorf("got original subject %q", got)
+		}
+		h, err := r.OriginalHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != orig.Header {
+			t.Error("OriginalHeader does not match OriginalMessage")
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		m, err := ReadMessage(strings.NewReader(simpleMsg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := m.Report(); r != nil {
+			t.Errorf("got report %+v", r)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bobg/errors"
+)
+
+// SectionSpec returns the IMAP section specifier of p
+// (RFC3501 section 6.4.5, RFC9051 section 6.4.5)
+// within the message from which it was read:
+// "" for the message itself,
+// "1", "2", and so on for the parts of a top-level multipart,
+// "2.1", "2.2", and so on for their subparts,
+// and so on.
+//
+// The message encapsulated in a message/rfc822 (or message/global) part
+// has the same specifier as that part,
+// so that appending ".HEADER" or ".TEXT" addresses its header or its body.
+// Appending ".MIME" to the specifier of a part addresses that part's own header.
+//
+// Specifiers are assigned by ReadMessage and ReadMessageWithOptions.
+// After changing the structure of a message,
+// call Renumber to update them.
+func (p *Part) SectionSpec() string {
+	return p.section
+}
+
+// Renumber assigns IMAP section specifiers (see Part.SectionSpec)
+// to m and all the parts it contains.
+func (m *Message) Renumber() {
+	(*Part)(m).number("", true)
+}
+
+// number assigns the section specifier spec to p and the parts it contains.
+// If isMsg is true,
+// p is a message rather than a body part,
+// and a non-multipart body of it is numbered spec.1.
+func (p *Part) number(spec string, isMsg bool) {
+	p.section = spec
+	switch body := p.B.(type) {
+	case *Multipart:
+		for i, subpart := range body.Parts {
+			subpart.number(subSectionSpec(spec, i+1), false)
+		}
+	case *Message:
+		if isMsg {
+			(*Part)(body).number(subSectionSpec(spec, 1), true)
+		} else {
+			(*Part)(body).number(spec, true)
+		}
+	}
+}
+
+func subSectionSpec(spec string, n int) string {
+	if spec == "" {
+		return strconv.Itoa(n)
+	}
+	return spec + "." + strconv.Itoa(n)
+}
+
+// ErrNoSection is the error indicating that an IMAP section specifier
+// does not address anything in a message.
+var ErrNoSection = errors.New("no such section")
+
+// Section resolves the IMAP section specifier spec within m
+// the way IMAP FETCH BODY[spec] does
+// (RFC3501 section 6.4.5, RFC9051 section 6.4.5).
+// It returns the part that spec addresses
+// and the content IMAP would return for it.
+//
+// The spec is a dotted sequence of part numbers ("2.1"),
+// optionally followed by
+// HEADER, HEADER.FIELDS (field-list), HEADER.FIELDS.NOT (field-list), TEXT, or MIME
+// ("2.1.MIME", "HEADER.FIELDS (From To)").
+// The empty spec addresses the whole message.
+// With HEADER or TEXT,
+// the returned part is the message whose header or body is addressed:
+// m itself with no part numbers,
+// otherwise the message encapsulated in a message/rfc822 part.
+//
+// The content is in canonical CRLF form,
+// consistent with the sizes in BodyStructure.
+func (m *Message) Section(spec string) (*Part, []byte, error) {
+	var (
+		nums []int
+		rest = spec
+	)
+	for rest != "" {
+		tok, after, found := strings.Cut(rest, ".")
+		if !isDigits(tok) {
+			break
+		}
+		n, err := strconv.Atoi(tok)
+		if err != nil || n < 1 {
+			return nil, nil, fmt.Errorf("bad part number %s in section spec %s", tok, spec)
+		}
+		nums = append(nums, n)
+		if found && after == "" {
+			return nil, nil, fmt.Errorf("bad section spec %s", spec)
+		}
+		rest = after
+	}
+
+	var (
+		container = (*Part)(m)
+		p         = container
+		bounded   bool // whether p is followed by a multipart boundary
+	)
+	for i, n := range nums {
+		if i > 0 {
+			switch body := p.B.(type) {
+			case *Message:
+				container = (*Part)(body)
+			case *Multipart:
+				container = p
+			default:
+				return nil, nil, errors.Wrapf(ErrNoSection, "%s has no subparts", p.section)
+			}
+		}
+		if mp, ok := container.B.(*Multipart); ok {
+			if n > len(mp.Parts) {
+				return nil, nil, errors.Wrapf(ErrNoSection, "%s", spec)
+			}
+			p = mp.Parts[n-1]
+			bounded = true
+		} else if n == 1 {
+			p = container
+		} else {
+			return nil, nil, errors.Wrapf(ErrNoSection, "%s", spec)
+		}
+	}
+
+	keyword, fieldList, _ := strings.Cut(rest, " ")
+	keyword = strings.ToUpper(keyword)
+
+	switch keyword {
+	case "":
+		buf := new(bytes.Buffer)
+		if len(nums) == 0 {
+			if _, err := m.WriteTo(buf); err != nil {
+				return nil, nil, errors.Wrap(err, "rendering message")
+			}
+		} else if _, err := p.writeBody(buf); err != nil {
+			return nil, nil, errors.Wrapf(err, "rendering section %s", spec)
+		}
+		return p, imapBytes(buf.Bytes(), bounded), nil
+
+	case "MIME":
+		if len(nums) == 0 {
+			return nil, nil, fmt.Errorf("MIME requires a part number in section spec %s", spec)
+		}
+		return p, imapHeaderBytes(p.Header), nil
+	}
+
+	// The remaining keywords address a message.
+	if len(nums) > 0 {
+		inner, ok := p.B.(*Message)
+		if !ok {
+			return nil, nil, errors.Wrapf(ErrNoSection, "%s is not a message/rfc822 part", p.section)
+		}
+		p = (*Part)(inner)
+	}
+
+	switch keyword {
+	case "HEADER":
+		return p, imapHeaderBytes(p.Header), nil
+
+	case "HEADER.FIELDS", "HEADER.FIELDS.NOT":
+		names := strings.Fields(strings.NewReplacer("(", " ", ")", " ", `"`, " ").Replace(fieldList))
+		if len(names) == 0 {
+			return nil, nil, fmt.Errorf("no field names in section spec %s", spec)
+		}
+		not := keyword == "HEADER.FIELDS.NOT"
+		h := &Header{DefaultType: p.DefaultType}
+		for _, f := range p.Fields {
+			if containsFold(names, strings.TrimSpace(f.N)) != not {
+				h.Fields = append(h.Fields, f)
+			}
+		}
+		return p, imapHeaderBytes(h), nil
+
+	case "TEXT":
+		buf := new(bytes.Buffer)
+		if _, err := p.writeBody(buf); err != nil {
+			return nil, nil, errors.Wrapf(err, "rendering section %s", spec)
+		}
+		return p, imapBytes(buf.Bytes(), bounded), nil
+	}
+
+	return nil, nil, fmt.Errorf("bad section spec %s", spec)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func imapHeaderBytes(h *Header) []byte {
+	buf := new(bytes.Buffer)
+	h.WriteTo(buf) // writing to a bytes.Buffer does not fail
+	return imapBytes(buf.Bytes(), false)
+}
+
+// imapBytes converts b to canonical CRLF form.
+// If bounded is true,
+// b is followed by a multipart boundary,
+// so its final line ending is removed first.
+func imapBytes(b []byte, bounded bool) []byte {
+	if bounded {
+		b = trimEOL(b)
+	}
+	size, _ := imapSize(b)
+	result := make([]byte, 0, size)
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			result = append(result, '\r')
+		}
+		result = append(result, c)
+	}
+	return result
+}
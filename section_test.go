@@ -0,0 +1,168 @@
+package rmime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSection(t *testing.T) {
+	const inp = `From: a@example.com
+Subject: outer
+Content-Type: multipart/mixed; boundary=b
+
+--b
+
+one
+--b
+Content-Type: message/rfc822
+
+From: c@example.com
+Subject: inner
+Content-Type: multipart/alternative; boundary=c
+
+--c
+
+two.one
+--c
+Content-Type: text/html
+
+two.two
+--c--
+--b
+Content-Type: message/rfc822
+
+Subject: simple
+
+three
+--b--
+`
+
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		spec     string
+		wantPart string // SectionSpec of the resulting part
+		want     string
+		wantErr  bool
+	}{{
+		spec:     "1",
+		wantPart: "1",
+		want:     "one",
+	}, {
+		spec:     "1.MIME",
+		wantPart: "1",
+		want:     "\r\n",
+	}, {
+		spec:     "2.MIME",
+		wantPart: "2",
+		want:     "Content-Type: message/rfc822\r\n\r\n",
+	}, {
+		spec:     "2.HEADER",
+		wantPart: "2",
+		want:     "From: c@example.com\r\nSubject: inner\r\nContent-Type: multipart/alternative; boundary=c\r\n\r\n",
+	}, {
+		spec:     "2.HEADER.FIELDS (SUBJECT from)",
+		wantPart: "2",
+		want:     "From: c@example.com\r\nSubject: inner\r\n\r\n",
+	}, {
+		spec:     "2.header.fields.not (Content-Type)",
+		wantPart: "2",
+		want:     "From: c@example.com\r\nSubject: inner\r\n\r\n",
+	}, {
+		spec:     "2.TEXT",
+		wantPart: "2",
+		want:     "--c\r\n\r\ntwo.one\r\n--c\r\nContent-Type: text/html\r\n\r\ntwo.two\r\n--c--",
+	}, {
+		spec:     "2.2",
+		wantPart: "2.2",
+		want:     "two.two",
+	}, {
+		spec:     "2.2.MIME",
+		wantPart: "2.2",
+		want:     "Content-Type: text/html\r\n\r\n",
+	}, {
+		spec:     "3.1",
+		wantPart: "3",
+		want:     "three",
+	}, {
+		spec:     "3.HEADER",
+		wantPart: "3",
+		want:     "Subject: simple\r\n\r\n",
+	}, {
+		spec:     "HEADER.FIELDS (Subject)",
+		wantPart: "",
+		want:     "Subject: outer\r\n\r\n",
+	}, {
+		spec:    "4",
+		wantErr: true,
+	}, {
+		spec:    "1.1",
+		wantErr: true,
+	}, {
+		spec:    "1.HEADER",
+		wantErr: true,
+	}, {
+		spec:    "MIME",
+		wantErr: true,
+	}, {
+		spec:    "2.",
+		wantErr: true,
+	}, {
+		spec:    "0",
+		wantErr: true,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			p, got, err := m.Section(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error for %s", tc.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.SectionSpec() != tc.wantPart {
+				t.Errorf("got part %q, want %q", p.SectionSpec(), tc.wantPart)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, _, err := m.Section("5"); !errors.Is(err, ErrNoSection) {
+		t.Errorf("got error %v, want %v", err, ErrNoSection)
+	}
+
+	// Sizes must agree with BODYSTRUCTURE.
+	_, text, err := m.Section("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.BodyStructure(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf(`"7BIT" %d 1)`, len(text)); !strings.Contains(bs, want) {
+		t.Errorf("BODYSTRUCTURE %s does not contain %s", bs, want)
+	}
+
+	// Renumbering after a structural change.
+	mp := m.B.(*Multipart)
+	mp.Parts = mp.Parts[1:]
+	m.Renumber()
+	if got := mp.Parts[0].SectionSpec(); got != "1" {
+		t.Errorf("after renumbering, got %q, want 1", got)
+	}
+	if got := mp.Parts[0].B.(*Message).B.(*Multipart).Parts[1].SectionSpec(); got != "1.2" {
+		t.Errorf("after renumbering, got %q, want 1.2", got)
+	}
+}
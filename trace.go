@@ -0,0 +1,230 @@
+package rmime
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Hop is one step in the transmission of a message,
+// parsed from a Received field
+// (RFC5321 section 4.4, RFC5322 section 3.6.7).
+// Any element not present in the field is empty.
+type Hop struct {
+	// From is the name the sending host gave for itself
+	// (e.g. in its EHLO command),
+	// or its address literal.
+	From string `json:"from,omitempty"`
+
+	// FromRDNS and FromIP are the name (from reverse DNS)
+	// and IP address of the sending host,
+	// as recorded by the receiving host in the TCP-info comment
+	// following the from clause,
+	// e.g. "(mail.example.com [192.0.2.1])".
+	FromRDNS string     `json:"from_rdns,omitempty"`
+	FromIP   netip.Addr `json:"from_ip,omitempty"`
+
+	// By is the name of the receiving host.
+	By string `json:"by,omitempty"`
+
+	Via  string `json:"via,omitempty"`  // The link type, e.g. "UUCP".
+	With string `json:"with,omitempty"` // The protocol, e.g. "ESMTPS".
+	ID   string `json:"id,omitempty"`   // The receiving host's ID for the message.
+	For  string `json:"for,omitempty"`  // The recipient, e.g. "<user@example.com>".
+
+	// Date is when the receiving host received the message.
+	// It is the zero time if absent or unparseable.
+	Date time.Time `json:"date,omitempty"`
+
+	// Delay is the time from the previous hop's Date to this one's.
+	// It is zero for the first hop
+	// and when either Date is unknown.
+	// It can be negative,
+	// when the hosts' clocks disagree.
+	Delay time.Duration `json:"delay,omitempty"`
+
+	// Raw is the value of the Received field.
+	Raw string `json:"raw"`
+}
+
+// Trace parses the Received fields of h,
+// returning the hops they describe
+// in the order they occurred:
+// oldest (the first host to receive the message) to newest.
+//
+// Parsing is tolerant of the many nonstandard formats in use:
+// clauses may be missing, out of order, or contain multiple words;
+// comments anywhere are ignored
+// (except for the TCP-info comment of the from clause);
+// and a date need not follow a semicolon.
+func (h Header) Trace() []*Hop {
+	vals := h.fieldValues("Received")
+	hops := make([]*Hop, 0, len(vals))
+
+	// Fields are prepended by each receiving host,
+	// so the oldest is last.
+	for i := len(vals) - 1; i >= 0; i-- {
+		hop := parseReceived(vals[i])
+		if len(hops) > 0 {
+			if prev := hops[len(hops)-1]; !prev.Date.IsZero() && !hop.Date.IsZero() {
+				hop.Delay = hop.Date.Sub(prev.Date)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// receivedDateRegex matches a date-time in a Received field
+// whose date does not follow a semicolon.
+var receivedDateRegex = regexp.MustCompile(`(?i)((mon|tue|wed|thu|fri|sat|sun),?\s+)?\d{1,2}\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)\s+\d{2,4}\s+\d{1,2}:\d\d(:\d\d)?(\s+([+-]\d{4}|[a-z]{1,5}))?(\s*\([^()]*\))?\s*$`)
+
+func parseReceived(v string) *Hop {
+	hop := &Hop{Raw: v}
+
+	clauses := v
+	if i := lastUnquoted(v, ';'); i >= 0 {
+		clauses = v[:i]
+		hop.Date = parseDate(strings.TrimSpace(v[i+1:]))
+	} else if loc := receivedDateRegex.FindStringIndex(v); loc != nil {
+		clauses = v[:loc[0]]
+		hop.Date = parseDate(strings.TrimSpace(v[loc[0]:]))
+	}
+
+	var (
+		clause      *string
+		fromComment string
+	)
+	for _, tok := range receivedTokens(clauses) {
+		if strings.HasPrefix(tok, "(") {
+			if clause == &hop.From && fromComment == "" {
+				fromComment = strings.TrimSuffix(tok[1:], ")")
+			}
+			continue
+		}
+		switch strings.ToLower(tok) {
+		case "from":
+			clause = &hop.From
+			continue
+		case "by":
+			clause = &hop.By
+			continue
+		case "via":
+			clause = &hop.Via
+			continue
+		case "with":
+			clause = &hop.With
+			continue
+		case "id":
+			clause = &hop.ID
+			continue
+		case "for":
+			clause = &hop.For
+			continue
+		}
+		if clause == nil {
+			continue
+		}
+		if *clause != "" {
+			*clause += " "
+		}
+		*clause += tok
+	}
+
+	hop.FromRDNS, hop.FromIP = parseTCPInfo(fromComment)
+	if !hop.FromIP.IsValid() {
+		hop.FromIP = parseIPLiteral(hop.From)
+	}
+	return hop
+}
+
+// receivedTokens splits the clauses of a Received field
+// into words, comments (including their parentheses),
+// and bracketed or quoted strings.
+func receivedTokens(s string) []string {
+	var toks []string
+	for pos := 0; pos < len(s); {
+		start := pos
+		switch s[pos] {
+		case ' ', '\t', '\r', '\n':
+			pos++
+			continue
+		case '(':
+			pos = skipComment(s, pos)
+		case '"':
+			pos = skipQuoted(s, pos)
+		case '<', '[':
+			close := byte('>')
+			if s[pos] == '[' {
+				close = ']'
+			}
+			if i := strings.IndexByte(s[pos:], close); i >= 0 {
+				pos += i + 1
+			} else {
+				pos = len(s)
+			}
+		default:
+			for pos < len(s) && strings.IndexByte(" \t\r\n(\"<", s[pos]) < 0 {
+				pos++
+			}
+		}
+		toks = append(toks, s[start:pos])
+	}
+	return toks
+}
+
+// parseTCPInfo extracts the host name and IP address
+// from a TCP-info comment,
+// in any of its common forms, e.g.:
+//
+//	mail.example.com [192.0.2.1]
+//	mail.example.com. [192.0.2.1] (may be forged)
+//	[IPv6:2001:db8::1]
+//	unknown [192.0.2.1]
+//	192.0.2.1
+//	HELO client.example) (192.0.2.1
+//	[192.0.2.1] helo=client.example
+func parseTCPInfo(s string) (rdns string, ip netip.Addr) {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '(' || r == ')'
+	})
+	for i, w := range words {
+		if a := parseIPLiteral(w); a.IsValid() {
+			if !ip.IsValid() {
+				ip = a
+			}
+			continue
+		}
+		if a, err := netip.ParseAddr(w); err == nil {
+			if !ip.IsValid() {
+				ip = a
+			}
+			continue
+		}
+		if i > 0 && strings.EqualFold(words[i-1], "helo") {
+			continue
+		}
+		if rdns == "" && strings.Contains(w, ".") && !strings.ContainsAny(w, "=:@[]") {
+			rdns = strings.TrimSuffix(w, ".")
+		}
+	}
+	return rdns, ip
+}
+
+// parseIPLiteral parses an address literal like "[192.0.2.1]" or "[IPv6:2001:db8::1]",
+// returning the zero netip.Addr if s is not one.
+func parseIPLiteral(s string) netip.Addr {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return netip.Addr{}
+	}
+	s = s[1 : len(s)-1]
+	if len(s) > 5 && strings.EqualFold(s[:5], "IPv6:") {
+		s = s[5:]
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return a
+}
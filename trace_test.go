@@ -0,0 +1,153 @@
+package rmime
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrace(t *testing.T) {
+	const inp = `Received: from mail-sor-f41.google.com (mail-sor-f41.google.com. [209.85.220.41])
+        by mx.google.com with SMTPS id x12sor123lkb.45.2020.01.06.10.00.05
+        for <user@example.net>
+        (Google Transport Security);
+        Mon, 06 Jan 2020 10:00:05 -0800 (PST)
+Received: from EXCH01.corp.example.com (10.0.0.1) by EXCH02.corp.example.com
+ (10.0.0.2) with Microsoft SMTP Server (version=TLS1_2,
+ cipher=TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384) id 15.1.2507.23 via Frontend
+ Transport; Mon, 6 Jan 2020 18:00:02 +0000
+Received: from [192.0.2.7] (helo=client.example)
+	by smtp.example.com with esmtpa (Exim 4.92)
+	(envelope-from <sender@example.com>)
+	id 1ioABC-0001xy-QR; Mon, 06 Jan 2020 17:59:58 +0000
+Received: (qmail 12345 invoked by uid 1000); 6 Jan 2020 17:59:57 -0000
+Received: from localhost by sender.example.com id AAA01234 Mon, 6 Jan 2020 09:59:59 -0800
+Subject: hello
+
+`
+	m, err := ReadMessage(strings.NewReader(inp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hops := m.Trace()
+	if len(hops) != 5 {
+		t.Fatalf("got %d hops, want 5", len(hops))
+	}
+
+	want := []Hop{{
+		From:  "localhost",
+		By:    "sender.example.com",
+		ID:    "AAA01234",
+		Date:  time.Date(2020, 1, 6, 17, 59, 59, 0, time.UTC),
+		Delay: 0,
+	}, {
+		Date:  time.Date(2020, 1, 6, 17, 59, 57, 0, time.UTC),
+		Delay: -2 * time.Second,
+	}, {
+		From:   "[192.0.2.7]",
+		FromIP: netip.MustParseAddr("192.0.2.7"),
+		By:     "smtp.example.com",
+		With:   "esmtpa",
+		ID:     "1ioABC-0001xy-QR",
+		Date:   time.Date(2020, 1, 6, 17, 59, 58, 0, time.UTC),
+		Delay:  time.Second,
+	}, {
+		From:   "EXCH01.corp.example.com",
+		FromIP: netip.MustParseAddr("10.0.0.1"),
+		By:     "EXCH02.corp.example.com",
+		Via:    "Frontend Transport",
+		With:   "Microsoft SMTP Server",
+		ID:     "15.1.2507.23",
+		Date:   time.Date(2020, 1, 6, 18, 0, 2, 0, time.UTC),
+		Delay:  4 * time.Second,
+	}, {
+		From:     "mail-sor-f41.google.com",
+		FromRDNS: "mail-sor-f41.google.com",
+		FromIP:   netip.MustParseAddr("209.85.220.41"),
+		By:       "mx.google.com",
+		With:     "SMTPS",
+		ID:       "x12sor123lkb.45.2020.01.06.10.00.05",
+		For:      "<user@example.net>",
+		Date:     time.Date(2020, 1, 6, 18, 0, 5, 0, time.UTC),
+		Delay:    3 * time.Second,
+	}}
+
+	for i, hop := range hops {
+		got := *hop
+		if got.Raw == "" {
+			t.Errorf("hop %d: empty Raw", i)
+		}
+		got.Raw = ""
+		if !got.Date.Equal(want[i].Date) {
+			t.Errorf("hop %d: got date %s, want %s", i, got.Date, want[i].Date)
+		}
+		got.Date = want[i].Date
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("hop %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestParseTCPInfo(t *testing.T) {
+	cases := []struct {
+		inp  string
+		rdns string
+		ip   string
+	}{
+		{inp: "mail.example.com [192.0.2.1]", rdns: "mail.example.com", ip: "192.0.2.1"},
+		{inp: "mail.example.com. [192.0.2.1] (may be forged)", rdns: "mail.example.com", ip: "192.0.2.1"},
+		{inp: "[IPv6:2001:db8::1]", ip: "2001:db8::1"},
+		{inp: "unknown [192.0.2.1]", ip: "192.0.2.1"},
+		{inp: "192.0.2.1", ip: "192.0.2.1"},
+		{inp: "HELO client.example) (192.0.2.1", ip: "192.0.2.1"},
+		{inp: "[192.0.2.1] helo=client.example", ip: "192.0.2.1"},
+		{inp: "Postfix"},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			rdns, ip := parseTCPInfo(tc.inp)
+			if rdns != tc.rdns {
+				t.Errorf("got rDNS %q, want %q", rdns, tc.rdns)
+			}
+			var gotIP string
+			if ip.IsValid() {
+				gotIP = ip.String()
+			}
+			if gotIP != tc.ip {
+				t.Errorf("got IP %q, want %q", gotIP, tc.ip)
+			}
+		})
+	}
+}
+
+func TestTraceMalformed(t *testing.T) {
+	cases := []struct {
+		inp      string
+		wantFrom string
+		wantBy   string
+	}{
+		{inp: `from a (\`, wantFrom: "a"},
+		{inp: `from a (unterminated by b`, wantFrom: "a"},
+		{inp: `from "a\`, wantFrom: `"a\`},
+		{inp: `from a by "b\"`, wantFrom: "a", wantBy: `"b\"`},
+		{inp: `from a by b (c (d\`, wantFrom: "a", wantBy: "b"},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			h := &Header{Fields: []*Field{{N: "Received", V: []string{" " + tc.inp}}}}
+			hops := h.Trace()
+			if len(hops) != 1 {
+				t.Fatalf("got %d hops, want 1", len(hops))
+			}
+			if hops[0].From != tc.wantFrom {
+				t.Errorf("got From %q, want %q", hops[0].From, tc.wantFrom)
+			}
+			if hops[0].By != tc.wantBy {
+				t.Errorf("got By %q, want %q", hops[0].By, tc.wantBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package rmime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bobg/errors"
+)
+
+// WalkFunc is the type of the function called by Walk for each part
+// of a message.
+//
+// For multipart/*, message/rfc822, and message/global parts,
+// body is nil,
+// and the calls for the part's children follow.
+// For all other parts,
+// body is a reader over the raw (still transfer-encoded) body of the part.
+// (That includes a multipart part that is read as text in lenient mode;
+// see ParseOptions.Lenient.)
+// (See DecodeBody.)
+// It is valid only until fn returns;
+// any portion of it left unread is skipped.
+//
+// If a WalkFunc returns an error,
+// Walk stops and returns that error.
+type WalkFunc func(h *Header, body io.Reader) error
+
+// Walk reads a message from r and calls fn for each of its parts,
+// in document order.
+// Unlike ReadMessage,
+// Walk never holds a whole body in memory:
+// multipart boundaries are detected on the fly,
+// and each body is streamed to fn,
+// so even huge messages are processed in constant space
+// (apart from headers,
+// and in lenient mode multipart preambles).
+func Walk(r Reader, fn WalkFunc) error {
+	_, err := WalkWithOptions(r, nil, fn)
+	return err
+}
+
+// WalkWithOptions is like Walk but is directed by opts,
+// which may be nil.
+// It also returns the warnings,
+// if any,
+// produced by recovering from malformed input in lenient mode.
+func WalkWithOptions(r Reader, opts *ParseOptions, fn WalkFunc) ([]*Warning, error) {
+	ps := newParser(opts)
+	err := ps.walkPart(r, "", fn)
+	return ps.warnings, err
+}
+
+func (ps *parser) walkPart(r Reader, defaultType string, fn WalkFunc) error {
+	defer ps.leavePart()
+	if err := ps.enterPart(); err != nil {
+		return err
+	}
+
+	if defaultType == "" {
+		defaultType = "text/plain"
+	}
+	h, r, err := ps.readHeader(r, defaultType)
+	if errors.Is(err, io.EOF) && ps.opts.Lenient {
+		ps.warn(WarnTruncatedHeader, "empty part")
+		h, err = &Header{DefaultType: defaultType}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch h.MajorType() {
+	case "multipart":
+		boundary := h.Params()["boundary"]
+		if boundary != "" {
+			return ps.walkMultipart(r, h, boundary, fn)
+		}
+		if !ps.opts.Lenient {
+			return fmt.Errorf("no boundary parameter in multipart Content-Type field")
+		}
+		ps.warn(WarnMissingBoundaryParam, "no boundary parameter for %s, reading as text", h.Type())
+
+	case "message":
+		switch h.MinorType() {
+		case "rfc822", "news", "global": // message/news == message/rfc822 per RFC5537, message/global is its RFC6532 counterpart
+			if err := fn(h, nil); err != nil {
+				return err
+			}
+			r, err := transferDecode(r, h)
+			if err != nil {
+				return err
+			}
+			return ps.walkPart(r, "", fn)
+		}
+	}
+
+	body := ps.bodyReader(r)
+	if err := fn(h, body); err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, body)
+	return err
+}
+
+func (ps *parser) walkMultipart(r Reader, h *Header, boundary string, fn WalkFunc) error {
+	childType := "text/plain"
+	if h.MinorType() == "digest" {
+		childType = "message/rfc822"
+	}
+
+	mr := newMultipartReader(r, boundary)
+	mr.lenient = ps.opts.Lenient
+	preamble, err := mr.nextPart()
+	if err != nil {
+		return err
+	}
+
+	// In lenient mode,
+	// a multipart body with no boundary line is read as text,
+	// so the preamble must be kept until it is known whether a boundary follows.
+	var (
+		text bytes.Buffer
+		dst  io.Writer = io.Discard
+	)
+	if ps.opts.Lenient {
+		dst = &text
+	}
+	if _, err := io.Copy(dst, ps.bodyReader(preamble)); err != nil {
+		return err
+	}
+	if preamble.truncated {
+		ps.warn(WarnNoBoundary, "no boundary line found in %s, reading as text", h.Type())
+		return fn(h, &text)
+	}
+	if err := fn(h, nil); err != nil {
+		return err
+	}
+	if preamble.final {
+		if ps.opts.Lenient {
+			ps.warn(WarnEmptyMultipart, "final multipart boundary encountered before any others")
+			return nil
+		}
+		return fmt.Errorf("final multipart boundary encountered before any others")
+	}
+	for {
+		pr, err := mr.nextPart()
+		if err != nil {
+			return err
+		}
+		if err := ps.walkPart(pr, childType, fn); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, pr); err != nil {
+			return err
+		}
+		if pr.truncated {
+			ps.warn(WarnUnterminatedMultipart, "input ended before the final boundary of %s", h.Type())
+		}
+		if pr.final {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package rmime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWalk(t *testing.T) {
+	big := strings.Repeat("0123456789abcdef", 1000) + "\n--xyzzy not a boundary\n--xyz--not one either\n"
+
+	inp := `From: foo
+Content-Type: multipart/mixed; boundary="xyz"
+
+preamble
+--xyz
+Content-Type: text/plain
+
+hello
+--xyz
+Content-Type: message/rfc822
+
+Subject: inner
+Content-Type: multipart/alternative; boundary=abc
+
+--abc
+
+inner text
+--abc
+Content-Type: text/html
+
+` + big + `--abc--
+--xyz
+Content-Type: application/octet-stream
+
+` + big + `
+--xyz--
+postamble
+`
+
+	type visit struct {
+		Type string
+		Body string
+		Nil  bool
+	}
+	want := []visit{
+		{Type: "multipart/mixed", Nil: true},
+		{Type: "text/plain", Body: "hello\n"},
+		{Type: "message/rfc822", Nil: true},
+		{Type: "multipart/alternative", Nil: true},
+		{Type: "text/plain", Body: "inner text\n"},
+		{Type: "text/html", Body: big},
+		{Type: "application/octet-stream", Body: big + "\n"},
+	}
+
+	readers := map[string]func(io.Reader) io.Reader{
+		"plain":    func(r io.Reader) io.Reader { return r },
+		"one_byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+	}
+
+	for name, wrap := range readers {
+		t.Run(name, func(t *testing.T) {
+			var got []visit
+			r := bufio.NewReader(wrap(strings.NewReader(inp)))
+			err := Walk(r, func(h *Header, body io.Reader) error {
+				v := visit{Type: h.Type(), Nil: body == nil}
+				if body != nil {
+					b, err := io.ReadAll(body)
+					if err != nil {
+						return err
+					}
+					v.Body = string(b)
+				}
+				got = append(got, v)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWalkTruncated(t *testing.T) {
+	inp := `Content-Type: multipart/mixed; boundary=xyz
+
+--xyz
+
+hello
+`
+	err := Walk(strings.NewReader(inp), func(*Header, io.Reader) error { return nil })
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestWalkLenientEmpty(t *testing.T) {
+	cases := []struct {
+		inp       string
+		wantTypes []string
+	}{
+		{inp: "Content-Type: multipart/mixed; boundary=x\n\n--x\n--x--\n", wantTypes: []string{"multipart/mixed", "text/plain"}},
+		{inp: "", wantTypes: []string{"text/plain"}},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			var gotTypes []string
+			warnings, err := WalkWithOptions(strings.NewReader(tc.inp), &ParseOptions{Lenient: true}, func(h *Header, _ io.Reader) error {
+				gotTypes = append(gotTypes, h.Type())
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotTypes, tc.wantTypes) {
+				t.Errorf("got types %v, want %v", gotTypes, tc.wantTypes)
+			}
+			if len(warnings) == 0 || warnings[0].Kind != WarnTruncatedHeader {
+				t.Errorf("got warnings %v, want %v first", warnings, WarnTruncatedHeader)
+			}
+		})
+	}
+}
+
+func TestWalkMatchesReadMessage(t *testing.T) {
+	cases := []string{
+		"Content-Type: multipart/mixed; boundary=b\n\nhello\n",
+		"Content-Type: multipart/mixed\n\nhello\n",
+		"Content-Type: message/x-unknown\n\nhello\n",
+		"Content-Type: multipart/mixed; boundary=b\n\npreamble\n--b\n\none\n--b\nContent-Type: multipart/alternative; boundary=c\n\nnothing here\n--b--\n",
+		"Content-Type: message/rfc822\n\nContent-Type: multipart/mixed; boundary=b\n\nhello\n",
+	}
+
+	var flatten func(*Part) []string
+	flatten = func(p *Part) []string {
+		switch b := p.B.(type) {
+		case string:
+			return []string{p.Type() + ": " + b}
+		case *Multipart:
+			result := []string{p.Type()}
+			for _, child := range b.Parts {
+				result = append(result, flatten(child)...)
+			}
+			return result
+		case *Message:
+			return append([]string{p.Type()}, flatten((*Part)(b))...)
+		}
+		return []string{fmt.Sprintf("%s: %T", p.Type(), p.B)}
+	}
+
+	for i, inp := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			opts := &ParseOptions{Lenient: true}
+
+			m, _, err := ReadMessageWithOptions(strings.NewReader(inp), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := flatten((*Part)(m))
+
+			var got []string
+			_, err = WalkWithOptions(strings.NewReader(inp), opts, func(h *Header, body io.Reader) error {
+				if body == nil {
+					got = append(got, h.Type())
+					return nil
+				}
+				b, err := io.ReadAll(body)
+				if err != nil {
+					return err
+				}
+				got = append(got, h.Type()+": "+string(b))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
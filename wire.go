@@ -0,0 +1,210 @@
+package rmime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// MaxLineLength is the limit on the length of a line in a message,
+// excluding its CRLF (RFC5322 section 2.1.1).
+const MaxLineLength = 998
+
+// LineLengthError is the error produced when an Encoder encounters a line
+// longer than its limit.
+type LineLengthError struct {
+	Line   int64 // The line number, starting at 1.
+	Length int   // The length of the line so far.
+	Limit  int
+}
+
+func (e *LineLengthError) Error() string {
+	return fmt.Sprintf("line %d exceeds %d octets", e.Line, e.Limit)
+}
+
+// Encoder writes messages in wire format:
+// with CRLF line endings,
+// as required for SMTP transmission (RFC5321)
+// and for signature canonicalization (e.g. DKIM, RFC6376, and S/MIME, RFC8551).
+//
+// Every \n in its input that is not already preceded by \r,
+// and every \r that is not followed by \n,
+// is written as \r\n,
+// since SMTP does not permit bare line-ending characters (RFC5321 section 2.3.8).
+// Within a header field,
+// such a line ending is followed by a space if necessary,
+// so that it continues the field rather than ending it.
+// Other bytes are written unchanged.
+type Encoder struct {
+	// MaxLineLength, if positive,
+	// is the limit on the length of a line, excluding its CRLF,
+	// in place of the package constant MaxLineLength.
+	// If it is negative, there is no limit.
+	MaxLineLength int
+
+	// DotStuff causes the Encoder to prefix each line beginning with "." with another "."
+	// and to terminate its output with a line containing only ".",
+	// as in the SMTP DATA command (RFC5321 section 4.5.2).
+	DotStuff bool
+
+	w io.Writer
+}
+
+// NewEncoder produces a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the wire format of wt
+// (normally a *Message, but any io.WriterTo in this package may be used)
+// to the Encoder's underlying writer.
+// If a line exceeds the length limit,
+// Encode stops with a *LineLengthError,
+// having written only the lines preceding it.
+func (e *Encoder) Encode(wt io.WriterTo) error {
+	limit := e.MaxLineLength
+	if limit == 0 {
+		limit = MaxLineLength
+	}
+	bw := bufio.NewWriter(e.w)
+	cw := &crlfWriter{
+		w:           bw,
+		limit:       limit,
+		dotStuff:    e.DotStuff,
+		atLineStart: true,
+		lineNum:     1,
+	}
+	_, err := wt.WriteTo(cw)
+	if err == nil {
+		err = cw.finish()
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// crlfWriter is the io.Writer that does the work of an Encoder.
+type crlfWriter struct {
+	w        *bufio.Writer
+	limit    int
+	dotStuff bool
+
+	// The current line is held in line until it is complete,
+	// so that nothing of an overlong line is written.
+	line        []byte
+	stuffed     bool // whether line begins with a dot added by dot-stuffing
+	atLineStart bool
+	pendingCR   bool // whether the previous byte was a \r, not yet written
+	lineNum     int64
+}
+
+func (cw *crlfWriter) Write(buf []byte) (int, error) {
+	for i, c := range buf {
+		if cw.pendingCR {
+			cw.pendingCR = false
+			if c != '\n' {
+				// A bare CR.
+				if err := cw.endLine(); err != nil {
+					return i, err
+				}
+			}
+		}
+		switch c {
+		case '\r':
+			cw.pendingCR = true
+		case '\n':
+			if err := cw.endLine(); err != nil {
+				return i, err
+			}
+		default:
+			if err := cw.add(c); err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(buf), nil
+}
+
+func (cw *crlfWriter) add(c byte) error {
+	if cw.atLineStart {
+		cw.atLineStart = false
+		if cw.dotStuff && c == '.' {
+			cw.line = append(cw.line, '.')
+			cw.stuffed = true
+		}
+	}
+	cw.line = append(cw.line, c)
+	n := len(cw.line)
+	if cw.stuffed {
+		n--
+	}
+	if cw.limit > 0 && n > cw.limit {
+		return &LineLengthError{Line: cw.lineNum, Length: n, Limit: cw.limit}
+	}
+	return nil
+}
+
+func (cw *crlfWriter) endLine() error {
+	cw.line = append(cw.line, '\r', '\n')
+	if _, err := cw.w.Write(cw.line); err != nil {
+		return err
+	}
+	cw.line = cw.line[:0]
+	cw.stuffed = false
+	cw.atLineStart = true
+	cw.lineNum++
+	return nil
+}
+
+// foldBreaks returns s with a space inserted after each line break
+// (\r\n, or a bare \r or \n)
+// that is followed by anything other than a space or tab,
+// so that in a header field it is a fold and not the end of the field.
+func foldBreaks(s string) string {
+	var (
+		buf  []byte
+		from int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\r' && c != '\n' {
+			continue
+		}
+		if c == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+			i++
+		}
+		if i+1 < len(s) && s[i+1] != ' ' && s[i+1] != '\t' {
+			buf = append(buf, s[from:i+1]...)
+			buf = append(buf, ' ')
+			from = i + 1
+		}
+	}
+	if buf == nil {
+		return s
+	}
+	return string(append(buf, s[from:]...))
+}
+
+// finish writes any incomplete final line,
+// terminating it with CRLF if dot-stuffing,
+// followed by the dot-stuffing terminator.
+func (cw *crlfWriter) finish() error {
+	if cw.pendingCR {
+		cw.pendingCR = false
+		if err := cw.endLine(); err != nil {
+			return err
+		}
+	}
+	if !cw.dotStuff {
+		_, err := cw.w.Write(cw.line)
+		return err
+	}
+	if !cw.atLineStart {
+		if err := cw.endLine(); err != nil {
+			return err
+		}
+	}
+	_, err := cw.w.WriteString(".\r\n")
+	return err
+}
@@ -0,0 +1,117 @@
+package rmime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	long := strings.Repeat("x", MaxLineLength)
+
+	cases := []struct {
+		inp      string
+		dotStuff bool
+		max      int
+		want     string
+		wantLine int64 // for a *LineLengthError
+	}{{
+		inp:  "Subject: hi\n\nhello\n",
+		want: "Subject: hi\r\n\r\nhello\r\n",
+	}, {
+		inp:  "Subject: hi\r\n\r\nmixed\nendings\r\nbare\rcr\n",
+		want: "Subject: hi\r\n\r\nmixed\r\nendings\r\nbare\r\ncr\r\n",
+	}, {
+		inp:  "Subject: hi\n\nbare\r\r\nat end\r",
+		want: "Subject: hi\r\n\r\nbare\r\n\r\nat end\r\n",
+	}, {
+		inp:  "Subject: a\rb\nTo: c\r\n\r\nbare\rcr\n",
+		want: "Subject: a\r\n b\r\nTo: c\r\n\r\nbare\r\ncr\r\n",
+	}, {
+		inp:  "Subject: hi\n\n.one\n..two\nthree.\n",
+		want: "Subject: hi\r\n\r\n.one\r\n..two\r\nthree.\r\n",
+	}, {
+		inp:      "Subject: hi\n\n.one\n..two\nthree.\n",
+		dotStuff: true,
+		want:     "Subject: hi\r\n\r\n..one\r\n...two\r\nthree.\r\n.\r\n",
+	}, {
+		inp:      "Subject: hi\n\nno final newline",
+		dotStuff: true,
+		want:     "Subject: hi\r\n\r\nno final newline\r\n.\r\n",
+	}, {
+		inp:  "Subject: hi\n\n" + long + "\n",
+		want: "Subject: hi\r\n\r\n" + long + "\r\n",
+	}, {
+		inp:      "Subject: hi\n\n." + long[1:] + "\n",
+		dotStuff: true,
+		want:     "Subject: hi\r\n\r\n.." + long[1:] + "\r\n.\r\n",
+	}, {
+		inp:      "Subject: hi\n\n" + long + "x\n",
+		want:     "Subject: hi\r\n\r\n",
+		wantLine: 3,
+	}, {
+		inp:      "Subject: hi\n\nshort\n",
+		max:      4,
+		want:     "",
+		wantLine: 1,
+	}, {
+		inp:  "Subject: hi\n\n" + long + "x\n",
+		max:  -1,
+		want: "Subject: hi\r\n\r\n" + long + "x\r\n",
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			m, _, err := ReadMessageWithOptions(strings.NewReader(tc.inp), &ParseOptions{PreserveRaw: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := new(bytes.Buffer)
+			enc := NewEncoder(buf)
+			enc.DotStuff = tc.dotStuff
+			enc.MaxLineLength = tc.max
+			err = enc.Encode(m)
+			if tc.wantLine > 0 {
+				var lerr *LineLengthError
+				if !errors.As(err, &lerr) {
+					t.Fatalf("got error %v, want a LineLengthError", err)
+				}
+				if lerr.Line != tc.wantLine {
+					t.Errorf("got error on line %d, want %d", lerr.Line, tc.wantLine)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncoderFieldBreaks(t *testing.T) {
+	cases := []struct {
+		v    []string
+		want string
+	}{
+		{v: []string{" a\rb"}, want: "Subject: a\r\n b\r\n"},
+		{v: []string{" a\nb"}, want: "Subject: a\r\n b\r\n"},
+		{v: []string{" a\r\nb"}, want: "Subject: a\r\n b\r\n"},
+		{v: []string{" a\r\tb"}, want: "Subject: a\r\n\tb\r\n"},
+		{v: []string{" a\r"}, want: "Subject: a\r\n"},
+		{v: []string{" a", " b"}, want: "Subject: a\r\n b\r\n"},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := NewEncoder(buf).Encode(Field{N: "Subject", V: tc.v}); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package rmime
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"io"
+	"mime/quotedprintable"
+	"strings"
 )
 
 // WriteTo implements the io.WriterTo interface.
+// Lines end with \n
+// (except where original line endings are kept, see ParseOptions.PreserveRaw).
+// For wire format, see Encoder.
 func (m *Message) WriteTo(w io.Writer) (int64, error) {
 	return (*Part)(m).WriteTo(w)
 }
@@ -15,34 +23,46 @@ func (p *Part) WriteTo(w io.Writer) (int64, error) {
 	if err != nil {
 		return n, err
 	}
+	n2, err := p.writeBody(w)
+	return n + n2, err
+}
+
+// writeBody writes the body of p to w.
+func (p *Part) writeBody(w io.Writer) (int64, error) {
+	var n int64
 
-	switch p.MajorType() {
-	case "multipart":
+	switch body := p.B.(type) {
+	case *Multipart:
 		params := p.Params()
 		boundary := params["boundary"]
 		if boundary == "" {
 			boundary = "x"
 		}
+		delims := body.delims
+		if len(delims) != len(body.Parts)+1 || body.boundary != boundary {
+			// Not recorded (see ParseOptions.PreserveRaw),
+			// or no longer applicable.
+			delims = nil
+		}
+		delim := func(i int) string {
+			switch {
+			case delims != nil:
+				return delims[i]
+			case i == len(body.Parts):
+				return "--" + boundary + "--\n"
+			default:
+				return "--" + boundary + "\n"
+			}
+		}
 
-		body := p.B.(*Multipart)
 		n2, err := w.Write([]byte(body.Preamble)) // note, this assumes Preamble ends in a newline
 		n += int64(n2)
 		if err != nil {
 			return n, err
 		}
 
-		for _, subpart := range body.Parts {
-			n2, err = w.Write([]byte("--"))
-			n += int64(n2)
-			if err != nil {
-				return n, err
-			}
-			n2, err = w.Write([]byte(boundary))
-			n += int64(n2)
-			if err != nil {
-				return n, err
-			}
-			n2, err = w.Write([]byte("\n"))
+		for i, subpart := range body.Parts {
+			n2, err = w.Write([]byte(delim(i)))
 			n += int64(n2)
 			if err != nil {
 				return n, err
@@ -54,22 +74,7 @@ func (p *Part) WriteTo(w io.Writer) (int64, error) {
 				return n, err
 			}
 		}
-		n2, err = w.Write([]byte("--"))
-		n += int64(n2)
-		if err != nil {
-			return n, err
-		}
-		n2, err = w.Write([]byte(boundary))
-		n += int64(n2)
-		if err != nil {
-			return n, err
-		}
-		n2, err = w.Write([]byte("--"))
-		n += int64(n2)
-		if err != nil {
-			return n, err
-		}
-		n2, err = w.Write([]byte("\n"))
+		n2, err = w.Write([]byte(delim(len(body.Parts))))
 		n += int64(n2)
 		if err != nil {
 			return n, err
@@ -78,29 +83,30 @@ func (p *Part) WriteTo(w io.Writer) (int64, error) {
 		n += int64(n2)
 		return n, err
 
-	case "message":
-		switch p.MinorType() {
-		case "rfc822", "news": // message/news == message/rfc822 per RFC5537
-			body := p.B.(*Message)
-			n2, err := body.WriteTo(w)
-			n += n2
-			return n, err
-
-		case "delivery-status":
-			body := p.B.(*DeliveryStatus)
-			n2, err := body.WriteTo(w)
-			n += n2
-			return n, err
-
-		default:
-			return n, ErrUnimplemented
+	case *Message, *Header, *DeliveryStatus, *DispositionNotification, *FeedbackReport:
+		// These may have been transfer-decoded by ReadBody (see transferDecode).
+		if p.rawBody != nil {
+			if sum, err := p.bodySum(); err == nil && bytes.Equal(sum, p.rawSum) {
+				n2, err := w.Write(p.rawBody)
+				return n + int64(n2), err
+			}
 		}
+		n2, err := writeEncoded(w, p.Encoding(), body.(io.WriterTo))
+		n += n2
+		return n, err
 
-	default:
-		body := p.B.(string)
+	case *ExternalBody:
+		n2, err := body.WriteTo(w)
+		n += n2
+		return n, err
+
+	case string:
 		n2, err := w.Write([]byte(body))
 		n += int64(n2)
 		return n, err
+
+	default:
+		return n, ErrUnimplemented
 	}
 }
 
@@ -114,24 +120,59 @@ func (h Header) WriteTo(w io.Writer) (int64, error) {
 			return n, err
 		}
 	}
-	_, err := w.Write([]byte("\n"))
-	n++
-	return n, err
+	end := h.end
+	if end == nil {
+		end = []byte("\n")
+	}
+	n2, err := w.Write(end)
+	return n + int64(n2), err
 }
 
 // WriteTo implements the io.WriterTo interface.
+// The first element of f.V is written on the line with the field name;
+// the others are written as continuation lines,
+// as ReadHeader produces them.
+// A field with no value elements is written with an empty value.
+// Values are written verbatim;
+// see NewField for producing properly encoded and folded ones.
+// (But in wire format,
+// where a bare \r or \n would end the field,
+// one within a value is followed by a space,
+// folding the field instead; see Encoder.)
 func (f Field) WriteTo(w io.Writer) (int64, error) {
-	var n int64
-	for _, v := range f.V {
-		n2, err := w.Write([]byte(f.N))
-		n += int64(n2)
-		if err != nil {
-			return n, err
+	_, wire := w.(*crlfWriter)
+	if f.raw != "" && f.rawMatches() {
+		raw := f.raw
+		if wire {
+			raw = foldBreaks(raw)
 		}
-		n2, err = w.Write([]byte(":"))
-		n += int64(n2)
-		if err != nil {
-			return n, err
+		n, err := io.WriteString(w, raw)
+		return int64(n), err
+	}
+	n2, err := w.Write([]byte(f.N))
+	n := int64(n2)
+	if err != nil {
+		return n, err
+	}
+	n2, err = w.Write([]byte(":"))
+	n += int64(n2)
+	if err != nil {
+		return n, err
+	}
+	vals := f.V
+	if len(vals) == 0 {
+		vals = []string{""}
+	}
+	for i, v := range vals {
+		if i > 0 && !isContinuationLine([]byte(v)) {
+			n2, err = w.Write([]byte(" "))
+			n += int64(n2)
+			if err != nil {
+				return n, err
+			}
+		}
+		if wire {
+			v = foldBreaks(v)
 		}
 		n2, err = w.Write([]byte(v))
 		n += int64(n2)
@@ -147,6 +188,45 @@ func (f Field) WriteTo(w io.Writer) (int64, error) {
 	return n, nil
 }
 
+// rawMatches tells whether f.raw still agrees with f.N and f.V,
+// i.e. whether f is unmodified since it was parsed.
+func (f Field) rawMatches() bool {
+	lines := strings.SplitAfter(f.raw, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) != len(f.V) {
+		return false
+	}
+	for i, line := range lines {
+		want := f.V[i]
+		if i == 0 {
+			want = f.N + ":" + want
+		}
+		if string(trimEOL([]byte(line))) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// bodySum returns a hash of the body of p,
+// before transfer encoding,
+// and of the transfer encoding.
+// It is used to tell whether a body recorded with ParseOptions.PreserveRaw has been modified.
+func (p *Part) bodySum() ([]byte, error) {
+	wt, ok := p.B.(io.WriterTo)
+	if !ok {
+		return nil, ErrUnimplemented
+	}
+	h := sha256.New()
+	io.WriteString(h, p.Encoding()+"\n")
+	if _, err := wt.WriteTo(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
 // WriteTo implements io.WriterTo.
 func (ds *DeliveryStatus) WriteTo(w io.Writer) (int64, error) {
 	n, err := ds.Message.WriteTo(w)
@@ -162,3 +242,109 @@ func (ds *DeliveryStatus) WriteTo(w io.Writer) (int64, error) {
 	}
 	return n, nil
 }
+
+// WriteTo implements io.WriterTo.
+func (eb *ExternalBody) WriteTo(w io.Writer) (int64, error) {
+	n, err := eb.Header.WriteTo(w)
+	if err != nil {
+		return n, err
+	}
+	n2, err := w.Write([]byte(eb.Body))
+	n += int64(n2)
+	return n, err
+}
+
+// WriteTo implements io.WriterTo.
+func (dn *DispositionNotification) WriteTo(w io.Writer) (int64, error) {
+	return dn.Header.WriteTo(w)
+}
+
+// writeEncoded writes wt to w,
+// applying the given content-transfer-encoding
+// if it is base64 or quoted-printable.
+func writeEncoded(w io.Writer, encoding string, wt io.WriterTo) (int64, error) {
+	var (
+		cw  = &countingWriter{w: w}
+		lw  *lineWrapper
+		enc io.WriteCloser
+	)
+	switch encoding {
+	case "base64":
+		lw = &lineWrapper{w: cw, max: 76}
+		enc = base64.NewEncoder(base64.StdEncoding, lw)
+	case "quoted-printable":
+		enc = quotedprintable.NewWriter(cw)
+	default:
+		return wt.WriteTo(w)
+	}
+	if _, err := wt.WriteTo(enc); err != nil {
+		return cw.n, err
+	}
+	if err := enc.Close(); err != nil {
+		return cw.n, err
+	}
+	if lw != nil {
+		if err := lw.finish(); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// countingWriter is an io.Writer that counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(buf []byte) (int, error) {
+	n, err := cw.w.Write(buf)
+	cw.n += int64(n)
+	return n, err
+}
+
+// lineWrapper is an io.Writer that breaks its input into lines of max bytes.
+type lineWrapper struct {
+	w   io.Writer
+	max int
+	col int
+}
+
+func (lw *lineWrapper) Write(buf []byte) (int, error) {
+	var n int
+	for len(buf) > 0 {
+		if lw.col == lw.max {
+			if _, err := lw.w.Write([]byte("\n")); err != nil {
+				return n, err
+			}
+			lw.col = 0
+		}
+		chunk := buf
+		if len(chunk) > lw.max-lw.col {
+			chunk = chunk[:lw.max-lw.col]
+		}
+		n2, err := lw.w.Write(chunk)
+		n += n2
+		lw.col += n2
+		if err != nil {
+			return n, err
+		}
+		buf = buf[n2:]
+	}
+	return n, nil
+}
+
+// finish terminates the last line, if necessary.
+func (lw *lineWrapper) finish() error {
+	if lw.col == 0 {
+		return nil
+	}
+	_, err := lw.w.Write([]byte("\n"))
+	lw.col = 0
+	return err
+}
+
+// WriteTo implements io.WriterTo.
+func (fr *FeedbackReport) WriteTo(w io.Writer) (int64, error) {
+	return fr.Header.WriteTo(w)
+}